@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Placeholder of an ANSI block in the markdown source, replaced once rendered: an escape
+// sequence ending with 'y', with the index of the block. Being a control sequence, it
+// can't be written by the text of the document. The bracket is escaped so it's not
+// parsed as markdown.
+const ansiBlockPlaceholder = "\x1b\\[%dy"
+
+// Rendered line containing a placeholder
+var ansiBlockPlaceholderRe = regexp.MustCompile(`(?m)^.*\x1b\[(\d+)y.*$`)
+
+// extractAnsiBlocks replaces the '```ansi' code blocks of the markdown source by
+// placeholders, returning their contents so they can be restored verbatim after rendering.
+func extractAnsiBlocks(content string) (string, []string) {
+	lines := strings.Split(content, "\n")
+	var out, blocks []string
+	for i := 0; i < len(lines); i++ {
+		f := fenceMarker(lines[i])
+		if f == "" {
+			out = append(out, lines[i])
+			continue
+		}
+
+		end := i + 1
+		for end < len(lines) && !strings.HasPrefix(fenceMarker(lines[end]), f) {
+			end++
+		}
+		info := strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(lines[i]), f[:1]))
+		if end == len(lines) || info != "ansi" {
+			// Keep other code blocks as is, including their contents
+			out = append(out, lines[i:min(end+1, len(lines))]...)
+			i = end
+			continue
+		}
+
+		// In its own paragraph, the whole rendered line being replaced
+		out = append(out, "", fmt.Sprintf(ansiBlockPlaceholder, len(blocks)), "")
+		blocks = append(blocks, strings.Join(lines[i+1:end], "\n"))
+		i = end
+	}
+	return strings.Join(out, "\n"), blocks
+}
+
+// restoreAnsiBlocks replaces the rendered lines containing a placeholder by the
+// contents of the ANSI block, indented by the margin. Styles are reset after the block.
+func restoreAnsiBlocks(rendered string, blocks []string, margin int) string {
+	return ansiBlockPlaceholderRe.ReplaceAllStringFunc(rendered, func(line string) string {
+		var i int
+		fmt.Sscanf(ansiBlockPlaceholderRe.FindStringSubmatch(line)[1], "%d", &i)
+		if i >= len(blocks) {
+			return line
+		}
+		indent := strings.Repeat(" ", margin)
+		return indent + strings.ReplaceAll(blocks[i], "\n", "\n"+indent) + "\x1b[0m"
+	})
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestExtractAnsiBlocks(t *testing.T) {
+	in := "text\n```ansi\n\x1b[31mred\x1b[0m\n```\n\n```go\n\x1b[32m\n```\n"
+	got, blocks := extractAnsiBlocks(in)
+	want := "text\n\n\x1b\\[0y\n\n\n```go\n\x1b[32m\n```\n"
+	if got != want {
+		t.Errorf("extractAnsiBlocks() = %q, want %q", got, want)
+	}
+	if !slices.Equal(blocks, []string{"\x1b[31mred\x1b[0m"}) {
+		t.Errorf("extractAnsiBlocks() blocks = %q", blocks)
+	}
+}
+
+func TestRenderAnsiBlocks(t *testing.T) {
+	m := testGlow()
+	m.ColorProfile, m.MarginLeft, m.AllowAnsi = "truecolor", 2, true
+	out, err := m.render("text glow-ansi-block-0 here\n```ansi\n\x1b[31mred\x1b[0m\n\x1b[1;32mbold green\x1b[0m\n```\nafter\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		// Escape sequences are kept verbatim, indented by the margin
+		"\n  \x1b[31mred\x1b[0m\n  \x1b[1;32mbold green\x1b[0m\n",
+		// Text looking like a placeholder is rendered as is
+		"text glow-ansi-block-0",
+		"after",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("render() = %q, should contain %q", out, want)
+		}
+	}
+}
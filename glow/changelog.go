@@ -0,0 +1,123 @@
+package main
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var (
+	versionRe    = regexp.MustCompile(`\bv?\d+\.\d+`)
+	unreleasedRe = regexp.MustCompile(`(?i)\bunreleased\b`)
+	headingRe    = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+	// Conventional commit subject, 'type(scope)!: description'
+	conventionalCommitRe = regexp.MustCompile(`^(\w+)(?:\(([^)]*)\))?(!)?:\s*(.+)$`)
+)
+
+// Section of the release notes, listing the commits of a conventional commit type
+type commitSection struct {
+	Type  string
+	Title string
+}
+
+// Sections of the release notes, in display order
+var commitSections = []commitSection{
+	{"feat", "Features"},
+	{"fix", "Bug Fixes"},
+	{"perf", "Performance"},
+	{"refactor", "Refactoring"},
+	{"docs", "Documentation"},
+	{"test", "Tests"},
+	{"build", "Build"},
+	{"ci", "CI"},
+	{"chore", "Chores"},
+}
+
+// latestChangelogSection extracts the first version section of a changelog,
+// with headings of the given level. Headings inside fenced code blocks are ignored.
+func latestChangelogSection(content string, level int, skipUnreleased bool) (string, bool) {
+	var section []string
+	inSection := false
+	fence := ""
+
+	for _, line := range strings.Split(content, "\n") {
+		if f := fenceMarker(line); f != "" {
+			if fence == "" {
+				fence = f
+			} else if strings.HasPrefix(f, fence) {
+				fence = ""
+			}
+		}
+		if fence == "" {
+			if match := headingRe.FindStringSubmatch(line); match != nil && len(match[1]) <= level {
+				if inSection {
+					break
+				}
+				title := match[2]
+				isUnreleased := unreleasedRe.MatchString(title)
+				if len(match[1]) == level && (versionRe.MatchString(title) || isUnreleased) && !(isUnreleased && skipUnreleased) {
+					inSection = true
+				}
+			}
+		}
+		if inSection {
+			section = append(section, line)
+		}
+	}
+
+	if !inSection {
+		return "", false
+	}
+	return strings.Join(section, "\n"), true
+}
+
+// fenceMarker returns the code fence marker starting the line, if any.
+func fenceMarker(line string) string {
+	trimmed := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 {
+		return ""
+	}
+	for _, c := range []string{"```", "~~~"} {
+		if strings.HasPrefix(trimmed, c) {
+			return trimmed[:len(trimmed)-len(strings.TrimLeft(trimmed, c[:1]))]
+		}
+	}
+	return ""
+}
+
+// releaseNotes formats commit subjects as markdown lists grouped by conventional
+// commit type. Commits without a known type are listed under 'Other', and the
+// commits keep their order in each section.
+func releaseNotes(commits []string) string {
+	items := map[string][]string{}
+	for _, commit := range commits {
+		subject, _, _ := strings.Cut(strings.TrimSpace(commit), "\n")
+		if subject == "" {
+			continue
+		}
+		match := conventionalCommitRe.FindStringSubmatch(subject)
+		if match == nil || !slices.ContainsFunc(commitSections, func(s commitSection) bool { return s.Type == strings.ToLower(match[1]) }) {
+			items["other"] = append(items["other"], "- "+subject)
+			continue
+		}
+		item := "- "
+		if match[3] != "" {
+			item += "**BREAKING** "
+		}
+		if match[2] != "" {
+			item += "**" + match[2] + ":** "
+		}
+		items[strings.ToLower(match[1])] = append(items[strings.ToLower(match[1])], item+match[4])
+	}
+
+	var b strings.Builder
+	b.WriteString("# Release Notes\n")
+	for _, section := range append(slices.Clone(commitSections), commitSection{"other", "Other"}) {
+		if len(items[section.Type]) == 0 {
+			continue
+		}
+		b.WriteString("\n## " + section.Title + "\n\n")
+		b.WriteString(strings.Join(items[section.Type], "\n") + "\n")
+	}
+	return b.String()
+}
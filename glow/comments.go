@@ -0,0 +1,90 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// HTML comment, possibly on multiple lines
+	htmlCommentRe = regexp.MustCompile(`(?s)<!--.*?-->`)
+	// Line of an indented code block, indented by at least 4 spaces or a tab
+	indentedCodeRe = regexp.MustCompile(`^(?: {4}|\t)`)
+)
+
+// stripComments removes the HTML comments of the markdown source.
+// Comments inside code blocks, fenced or indented, and code spans are kept as is.
+func stripComments(content string) string {
+	lines := strings.Split(content, "\n")
+	var out, text []string
+	flush := func() {
+		if len(text) > 0 {
+			out = append(out, stripTextComments(strings.Join(text, "\n")))
+			text = nil
+		}
+	}
+	fence := ""
+	// Inside an indented code block, a list or a comment
+	code, list, comment := false, false, false
+	prevBlank := true
+	for _, line := range lines {
+		if f := fenceMarker(line); f != "" {
+			if fence == "" {
+				flush()
+				fence = f
+				code, prevBlank = false, false
+			} else if strings.HasPrefix(f, fence) {
+				fence = ""
+			}
+			out = append(out, line)
+			continue
+		}
+		if fence != "" {
+			out = append(out, line)
+			continue
+		}
+
+		blank := strings.TrimSpace(line) == ""
+		switch {
+		case blank:
+		case indentedCodeRe.MatchString(line) && !list && !comment && (prevBlank || code):
+			code = true
+		default:
+			code = false
+			if listItemRe.MatchString(line) {
+				list = true
+			} else if !indentedCodeRe.MatchString(line) && !strings.HasPrefix(line, " ") {
+				list = false
+			}
+		}
+		prevBlank = blank
+		if code {
+			flush()
+			out = append(out, line)
+			continue
+		}
+		if open, close := strings.LastIndex(line, "<!--"), strings.LastIndex(line, "-->"); open >= 0 || close >= 0 {
+			comment = open > close
+		}
+		text = append(text, line)
+	}
+	flush()
+	return strings.Join(out, "\n")
+}
+
+// stripTextComments removes the HTML comments of markdown text without code blocks,
+// except the ones starting inside a code span.
+func stripTextComments(text string) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range htmlCommentRe.FindAllStringIndex(text, -1) {
+		lineStart := strings.LastIndex(text[:loc[0]], "\n") + 1
+		if strings.Count(text[lineStart:loc[0]], "`")%2 == 1 {
+			continue
+		}
+		b.WriteString(text[last:loc[0]])
+		last = loc[1]
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
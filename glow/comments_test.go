@@ -0,0 +1,49 @@
+package main
+
+import "testing"
+
+func TestStripComments(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "inline and multi-line",
+			in:   "a <!-- one --> b\n<!--\ntwo\n\n    still a comment\n-->\nc",
+			want: "a  b\n\nc",
+		},
+		{
+			name: "fenced code block",
+			in:   "text<!-- x -->\n```html\n<!-- kept -->\n```\n~~~\n<!-- kept -->\n~~~",
+			want: "text\n```html\n<!-- kept -->\n```\n~~~\n<!-- kept -->\n~~~",
+		},
+		{
+			name: "indented code block",
+			in:   "text<!-- x -->\n\n    <!-- kept -->\n\n    <!-- kept -->\n\t<!-- kept -->\nafter<!-- y -->",
+			want: "text\n\n    <!-- kept -->\n\n    <!-- kept -->\n\t<!-- kept -->\nafter",
+		},
+		{
+			name: "paragraph continuation",
+			in:   "text\n    <!-- x -->continued",
+			want: "text\n    continued",
+		},
+		{
+			name: "list item contents",
+			in:   "- item\n\n    <!-- x -->continued",
+			want: "- item\n\n    continued",
+		},
+		{
+			name: "code span",
+			in:   "`<!-- kept -->` <!-- x -->",
+			want: "`<!-- kept -->` ",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := stripComments(tt.in); got != tt.want {
+				t.Errorf("stripComments() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
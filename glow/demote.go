@@ -0,0 +1,55 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// Underline of a setext heading, '===' for level 1 or '---' for level 2
+	setextUnderlineRe = regexp.MustCompile(`^ {0,3}(=+|-+)\s*$`)
+	// Start of a block that can't be the text of a setext heading: list item, blockquote or table row
+	nonParagraphRe = regexp.MustCompile(`^\s*(?:[-*+>|]|\d+[.)])(?:\s|$)`)
+)
+
+// demoteHeadings shifts the level of the headings of the markdown source down by n,
+// up to level 6. Setext headings are converted to ATX ones, only when their text
+// is a single line.
+func demoteHeadings(content string, n int) string {
+	lines := strings.Split(content, "\n")
+	var out []string
+	fence := ""
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if f := fenceMarker(line); f != "" {
+			if fence == "" {
+				fence = f
+			} else if strings.HasPrefix(f, fence) {
+				fence = ""
+			}
+		}
+		if fence != "" {
+			out = append(out, line)
+			continue
+		}
+
+		if match := headingRe.FindStringSubmatch(line); match != nil {
+			out = append(out, strings.Repeat("#", min(len(match[1])+n, 6))+" "+match[2])
+			continue
+		}
+		single := i == 0 || strings.TrimSpace(lines[i-1]) == ""
+		if single && i+1 < len(lines) && strings.TrimSpace(line) != "" && !nonParagraphRe.MatchString(line) {
+			if match := setextUnderlineRe.FindStringSubmatch(lines[i+1]); match != nil {
+				level := 1
+				if match[1][0] == '-' {
+					level = 2
+				}
+				out = append(out, strings.Repeat("#", min(level+n, 6))+" "+strings.TrimSpace(line))
+				i++
+				continue
+			}
+		}
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n")
+}
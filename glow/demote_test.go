@@ -0,0 +1,44 @@
+package main
+
+import "testing"
+
+func TestDemoteHeadings(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		n    int
+		want string
+	}{
+		{
+			name: "atx",
+			in:   "# Title\n\ntext\n\n## Section",
+			n:    2,
+			want: "### Title\n\ntext\n\n#### Section",
+		},
+		{
+			name: "setext",
+			in:   "Title\n=====\n\nSection\n---\n\ntext",
+			n:    2,
+			want: "### Title\n\n#### Section\n\ntext",
+		},
+		{
+			name: "capped at h6",
+			in:   "# One\n#### Four\n###### Six",
+			n:    3,
+			want: "#### One\n###### Four\n###### Six",
+		},
+		{
+			name: "not headings",
+			in:   "```\n# code\n```\n\n- item\n---\n\nline one\nline two\n---",
+			n:    1,
+			want: "```\n# code\n```\n\n- item\n---\n\nline one\nline two\n---",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := demoteHeadings(tt.in, tt.n); got != tt.want {
+				t.Errorf("demoteHeadings() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// description is a summary of the contents of a markdown document
+type description struct {
+	ByteSize         int    `json:"byteSize"`
+	LineCount        int    `json:"lineCount"`
+	HeadingCount     int    `json:"headingCount"`
+	LinkCount        int    `json:"linkCount"`
+	ImageCount       int    `json:"imageCount"`
+	CodeBlockCount   int    `json:"codeBlockCount"`
+	DetectedLanguage string `json:"detectedLanguage"`
+}
+
+// describe parses the markdown source and counts its elements.
+// The detected language is the most used one by the fenced code blocks, the
+// first one in case of a tie.
+func (m *Glow) describe(content string) description {
+	source := []byte(content)
+	d := description{
+		ByteSize:  len(source),
+		LineCount: bytes.Count(source, []byte("\n")),
+	}
+	if len(source) > 0 && source[len(source)-1] != '\n' {
+		d.LineCount++
+	}
+
+	languages := map[string]int{}
+	doc := goldmark.New(goldmark.WithExtensions(m.extensions()...)).Parser().Parse(text.NewReader(source))
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *ast.Heading:
+			d.HeadingCount++
+		case *ast.Link, *ast.AutoLink:
+			d.LinkCount++
+		case *ast.Image:
+			d.ImageCount++
+		case *ast.CodeBlock:
+			d.CodeBlockCount++
+		case *ast.FencedCodeBlock:
+			d.CodeBlockCount++
+			if lang := string(n.Language(source)); lang != "" {
+				languages[lang]++
+				if languages[lang] > languages[d.DetectedLanguage] {
+					d.DetectedLanguage = lang
+				}
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	return d
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+
+	xansi "github.com/charmbracelet/x/ansi"
+)
+
+var (
+	// Collapsible section, with its attributes, optional summary and body
+	detailsRe = regexp.MustCompile(`(?s)^\s*<details(\s[^>]*)?>\s*(?:<summary>(.*?)</summary>)?(.*)</details>\s*$`)
+	// 'open' attribute of a section
+	openAttrRe = regexp.MustCompile(`(?i)(?:^|\s)open(?:\s|=|$)`)
+	// HTML tag, removed from the summaries
+	htmlTagRe = regexp.MustCompile(`<[^>]+>`)
+	// Marker of the start (1) or end (0) of the body of an expanded section, see detailsBlock
+	detailsMarkerRe = regexp.MustCompile(`\x1b\[([01])x`)
+	// Line made of section tags only, with the tags
+	detailsTagsLineRe = regexp.MustCompile(`^\s*(?:(?:<details(?:\s[^>]*)?>|</details>|<summary>.*?</summary>)\s*)+$`)
+	detailsTagRe      = regexp.MustCompile(`<details(\s[^>]*)?>|</details>|<summary>(.*?)</summary>`)
+	// Placeholder of the section tags in the HTML output, see htmlDetails
+	detailsPlaceholderRe = regexp.MustCompile(`<p>\x1b\[(\d+)x</p>`)
+)
+
+// details replaces the collapsible '<details>' sections of the markdown source, not
+// rendered by glamour, by their summary as a heading followed by their body, indented
+// once rendered by indentDetails. Sections are collapsed, showing an '[expand]' marker
+// instead of their body, unless they have the 'open' attribute or expand is set.
+func details(content string, expand bool) string {
+	lines := strings.Split(content, "\n")
+	var out []string
+	fence := ""
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if f := fenceMarker(line); f != "" {
+			if fence == "" {
+				fence = f
+			} else if strings.HasPrefix(f, fence) {
+				fence = ""
+			}
+		}
+		if fence != "" || !strings.HasPrefix(strings.TrimSpace(line), "<details") {
+			out = append(out, line)
+			continue
+		}
+
+		end := detailsEnd(lines, i)
+		if end < 0 {
+			out = append(out, line)
+			continue
+		}
+		out = append(out, detailsBlock(strings.Join(lines[i:end+1], "\n"), expand))
+		i = end
+	}
+	return strings.Join(out, "\n")
+}
+
+// detailsEnd returns the index of the line closing the section started at the given line,
+// taking nested sections into account, or -1 if the section is not closed.
+func detailsEnd(lines []string, start int) int {
+	depth := 0
+	for i := start; i < len(lines); i++ {
+		depth += strings.Count(lines[i], "<details") - strings.Count(lines[i], "</details>")
+		if depth <= 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+// detailsBlock converts a single '<details>' section to markdown.
+//
+// The body of an expanded section is surrounded by markers, escape sequences ending
+// with 'x' seen as zero width by the word wrap, in their own paragraphs. The bracket
+// is escaped so it's not parsed as markdown.
+func detailsBlock(block string, expand bool) string {
+	match := detailsRe.FindStringSubmatch(block)
+	if match == nil {
+		return block
+	}
+	summary := strings.TrimSpace(htmlTagRe.ReplaceAllString(match[2], ""))
+	if summary == "" {
+		summary = "Details"
+	}
+	if !expand && !openAttrRe.MatchString(match[1]) {
+		return "#### ▸ " + summary + " [expand]\n"
+	}
+
+	body := strings.TrimSpace(details(strings.TrimSpace(match[3]), expand))
+	return "#### ▾ " + summary + "\n\n\x1b\\[1x\n\n" + body + "\n\n\x1b\\[0x\n"
+}
+
+// indentDetails removes the markers of the rendered output, with the blank lines
+// separating them from the body of the sections, and indents the body.
+func indentDetails(out string) string {
+	var lines []string
+	depth := 0
+	start := false
+	for _, line := range strings.Split(out, "\n") {
+		blank := strings.TrimSpace(xansi.Strip(line)) == ""
+		if match := detailsMarkerRe.FindStringSubmatch(line); match != nil {
+			if match[1] == "1" {
+				depth++
+				start = true
+				continue
+			}
+			depth = max(depth-1, 0)
+			if n := len(lines); n > 0 && strings.TrimSpace(xansi.Strip(lines[n-1])) == "" {
+				lines = lines[:n-1]
+			}
+			continue
+		}
+		if start {
+			start = false
+			if blank {
+				continue
+			}
+		}
+		if depth > 0 && !blank {
+			line = strings.Repeat("  ", depth) + line
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// htmlDetails replaces the lines of the markdown source made of '<details>' and '<summary>'
+// tags by placeholders, as raw HTML is not part of the HTML output, returning the tags
+// to restore with restoreHTMLDetails. Only the 'open' attribute and the text of the
+// summaries are kept.
+func htmlDetails(content string) (string, []string) {
+	lines := strings.Split(content, "\n")
+	var tags []string
+	fence := ""
+	for i, line := range lines {
+		if f := fenceMarker(line); f != "" {
+			if fence == "" {
+				fence = f
+			} else if strings.HasPrefix(f, fence) {
+				fence = ""
+			}
+			continue
+		}
+		if fence != "" || !detailsTagsLineRe.MatchString(line) {
+			continue
+		}
+		var b strings.Builder
+		for _, match := range detailsTagRe.FindAllStringSubmatch(line, -1) {
+			switch {
+			case strings.HasPrefix(match[0], "</"):
+				b.WriteString("</details>")
+			case strings.HasPrefix(match[0], "<summary"):
+				b.WriteString("<summary>" + html.EscapeString(strings.TrimSpace(htmlTagRe.ReplaceAllString(match[2], ""))) + "</summary>")
+			case openAttrRe.MatchString(match[1]):
+				b.WriteString("<details open>")
+			default:
+				b.WriteString("<details>")
+			}
+		}
+		lines[i] = "\n\x1b\\[" + strconv.Itoa(len(tags)) + "x\n"
+		tags = append(tags, b.String())
+	}
+	return strings.Join(lines, "\n"), tags
+}
+
+// restoreHTMLDetails replaces the placeholders of the HTML output by the section tags.
+func restoreHTMLDetails(out string, tags []string) string {
+	return detailsPlaceholderRe.ReplaceAllStringFunc(out, func(p string) string {
+		n, err := strconv.Atoi(detailsPlaceholderRe.FindStringSubmatch(p)[1])
+		if err != nil || n >= len(tags) {
+			return p
+		}
+		return tags[n]
+	})
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Extensions of markdown files
+var markdownExtensions = map[string]bool{
+	".md":       true,
+	".markdown": true,
+	".mdown":    true,
+	".mkd":      true,
+	".mkdn":     true,
+}
+
+var markdownSignals = []*regexp.Regexp{
+	regexp.MustCompile(`^#{1,6}\s+\S`),              // ATX heading
+	regexp.MustCompile("^\\s*(```|~~~)"),            // code fence
+	regexp.MustCompile(`^\s*([-*+]|\d+[.)])\s+\S`),  // list item
+	regexp.MustCompile(`^\s*>\s?`),                  // blockquote
+	regexp.MustCompile(`!?\[[^\]]+\]\([^)]+\)`),     // link or image
+	regexp.MustCompile(`^\s*\|.*\|\s*$`),            // table row
+	regexp.MustCompile(`(\*\*|__)\S.*?\S(\*\*|__)`), // strong emphasis
+	regexp.MustCompile("`[^`]+`"),                   // code span
+}
+
+// isMarkdown detects if the content is markdown.
+//
+// Files with a markdown extension (.md, .markdown, .mdown, .mkd, .mkdn) are always
+// considered as markdown. Otherwise at least two different markdown constructs
+// (headings, fences, list items, blockquotes, links, table rows, strong emphasis,
+// code spans) must be found on different lines.
+func isMarkdown(name, content string) bool {
+	if markdownExtensions[strings.ToLower(path.Ext(name))] {
+		return true
+	}
+
+	found := map[int]bool{}
+	for _, line := range strings.Split(content, "\n") {
+		for i, re := range markdownSignals {
+			if re.MatchString(line) {
+				found[i] = true
+				break
+			}
+		}
+		if len(found) >= 2 {
+			return true
+		}
+	}
+	return false
+}
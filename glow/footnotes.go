@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var (
+	// Footnote definition, '[^label]: text'
+	footnoteDefRe = regexp.MustCompile(`^ {0,3}\[\^([^\]\s]+)\]:\s*(.*)$`)
+	// Footnote reference, '[^label]'
+	footnoteRefRe = regexp.MustCompile(`\[\^([^\]\s]+)\]`)
+)
+
+// footnotes replaces the footnote references of the markdown source by superscript
+// numbers and moves the definitions to a list at the end of the document.
+// Footnotes are numbered in the order of their first reference, references without
+// definition are kept as is and definitions never referenced are removed, like on GitHub.
+// Each definition ends with a back-reference to the sections referencing it, 'top'
+// being the beginning of the document before any heading.
+func footnotes(content string) string {
+	lines := strings.Split(content, "\n")
+	defs := map[string]string{}
+	var body []string
+	fence := ""
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if f := fenceMarker(line); f != "" {
+			if fence == "" {
+				fence = f
+			} else if strings.HasPrefix(f, fence) {
+				fence = ""
+			}
+		}
+		match := footnoteDefRe.FindStringSubmatch(line)
+		if fence != "" || match == nil {
+			body = append(body, line)
+			continue
+		}
+
+		// Indented lines continue the definition
+		text := strings.TrimSpace(match[2])
+		for i+1 < len(lines) && strings.TrimSpace(lines[i+1]) != "" && strings.TrimLeft(lines[i+1], " \t") != lines[i+1] {
+			i++
+			text += " " + strings.TrimSpace(lines[i])
+		}
+		if _, ok := defs[match[1]]; !ok {
+			defs[match[1]] = text
+		}
+	}
+	if len(defs) == 0 {
+		return content
+	}
+
+	var order []string
+	index := map[string]int{}
+	// Sections referencing each footnote
+	backrefs := map[string][]string{}
+	var sections []string
+	for _, section := range footnoteSections(body) {
+		sections = append(sections, replaceInline(strings.Join(section.lines, "\n"), func(text string) string {
+			return footnoteRefRe.ReplaceAllStringFunc(text, func(ref string) string {
+				label := footnoteRefRe.FindStringSubmatch(ref)[1]
+				if _, ok := defs[label]; !ok {
+					return ref
+				}
+				if _, ok := index[label]; !ok {
+					order = append(order, label)
+					index[label] = len(order)
+				}
+				if !slices.Contains(backrefs[label], section.title) {
+					backrefs[label] = append(backrefs[label], section.title)
+				}
+				return superscript(index[label])
+			})
+		}))
+	}
+	out := strings.Join(sections, "\n")
+	if len(order) == 0 {
+		return out
+	}
+
+	var b strings.Builder
+	b.WriteString(strings.TrimRight(out, "\n") + "\n\n---\n\n")
+	for i, label := range order {
+		fmt.Fprintf(&b, "%d. %s ↩ %s\n", i+1, defs[label], strings.Join(backrefs[label], ", "))
+	}
+	return b.String()
+}
+
+// footnoteSection is a part of the document, from a heading to the next one.
+type footnoteSection struct {
+	// Text of the heading, without footnote references, 'top' before the first heading
+	title string
+	lines []string
+}
+
+// footnoteSections splits the lines of the document at the headings outside code blocks.
+// Sections are never empty.
+func footnoteSections(lines []string) []footnoteSection {
+	var sections []footnoteSection
+	title := "top"
+	var current []string
+	fence := ""
+	for _, line := range lines {
+		if f := fenceMarker(line); f != "" {
+			if fence == "" {
+				fence = f
+			} else if strings.HasPrefix(f, fence) {
+				fence = ""
+			}
+		}
+		if match := headingRe.FindStringSubmatch(line); fence == "" && match != nil {
+			if len(current) > 0 {
+				sections = append(sections, footnoteSection{title: title, lines: current})
+			}
+			title = strings.TrimSpace(footnoteRefRe.ReplaceAllString(strings.TrimRight(match[2], " #"), ""))
+			current = nil
+		}
+		current = append(current, line)
+	}
+	return append(sections, footnoteSection{title: title, lines: current})
+}
+
+// superscript returns the number written with superscript digits.
+func superscript(n int) string {
+	digits := []rune("⁰¹²³⁴⁵⁶⁷⁸⁹")
+	var b strings.Builder
+	for _, d := range fmt.Sprint(n) {
+		b.WriteRune(digits[d-'0'])
+	}
+	return b.String()
+}
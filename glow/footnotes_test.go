@@ -0,0 +1,44 @@
+package main
+
+import "testing"
+
+func TestFootnotes(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "numbered by first reference",
+			in:   "Intro[^b].\n\n## Usage\n\nFirst[^a] and again[^b].\n\n[^a]: Note A.\n[^b]: Note B\n    continued.\n",
+			want: "Intro¹.\n\n## Usage\n\nFirst² and again¹.\n\n---\n\n1. Note B continued. ↩ top, Usage\n2. Note A. ↩ Usage\n",
+		},
+		{
+			name: "back-reference to the heading",
+			in:   "# Title\n\nText[^x] and more[^x].\n\n[^x]: X.\n",
+			want: "# Title\n\nText¹ and more¹.\n\n---\n\n1. X. ↩ Title\n",
+		},
+		{
+			name: "unused and undefined",
+			in:   "Text[^x] and [^missing].\n\n[^x]: X.\n[^unused]: Unused.\n",
+			want: "Text¹ and [^missing].\n\n---\n\n1. X. ↩ top\n",
+		},
+		{
+			name: "code blocks",
+			in:   "Text[^x].\n\n```\n[^x]\n# not a heading\n```\n\nAfter[^y].\n\n[^x]: X.\n[^y]: Y.\n",
+			want: "Text¹.\n\n```\n[^x]\n# not a heading\n```\n\nAfter².\n\n---\n\n1. X. ↩ top\n2. Y. ↩ top\n",
+		},
+		{
+			name: "no definition",
+			in:   "Text[^x].\n",
+			want: "Text[^x].\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := footnotes(tt.in); got != tt.want {
+				t.Errorf("footnotes() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"path"
+	"regexp"
+	"strings"
+
+	"dagger/glow/internal/dagger"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+)
+
+var pageTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{ .Title }}</title>
+<style>
+body { display: flex; margin: 0; font-family: sans-serif; line-height: 1.5; }
+nav { min-width: 14em; padding: 1em; background: #f6f8fa; }
+nav ul { list-style: none; padding: 0; }
+main { padding: 1em 2em; max-width: 50em; }
+pre { padding: 1em; overflow: auto; background: #f6f8fa; }
+table { border-collapse: collapse; }
+th, td { padding: .3em .8em; border: 1px solid #d0d7de; }
+.page-break { break-after: page; }
+@media print { body { display: block; } nav { display: none; } }
+</style>
+</head>
+<body>
+{{- if .Links }}
+<nav>
+<ul>
+{{- range .Links }}
+<li><a href="{{ .Href }}">{{ .Name }}</a></li>
+{{- end }}
+</ul>
+</nav>
+{{- end }}
+<main>
+{{ .Content }}
+</main>
+</body>
+</html>
+`))
+
+type pageLink struct {
+	Name string
+	Href string
+}
+
+type page struct {
+	Title   string
+	Links   []pageLink
+	Content template.HTML
+}
+
+// Placeholder of a page break in the markdown source, replaced once rendered to HTML
+const pageBreakPlaceholder = "glow-page-break"
+
+// Page break markers, alone on their line
+var pageBreakRe = regexp.MustCompile(`^\s*(?:<!--\s*pagebreak\s*-->|\\pagebreak|\\newpage)\s*$`)
+
+// renderHTML converts the markdown input to an HTML fragment.
+//
+// Page break markers ('<!-- pagebreak -->', '\pagebreak' or '\newpage' alone on their
+// line) are converted to CSS page breaks, used when printing the page. The collapsible
+// '<details>' sections are kept, their tags being alone on their lines.
+func (m *Glow) renderHTML(str string) (string, error) {
+	extensions := m.extensions()
+	if m.Footnotes && m.Flavor != "commonmark" {
+		// Rendered as HTML footnotes, with links between the references and the definitions
+		extensions = append(extensions, extension.Footnote)
+	}
+	md := goldmark.New(
+		goldmark.WithExtensions(extensions...),
+		goldmark.WithParserOptions(
+			parser.WithAutoHeadingID(),
+		),
+	)
+	src, tags := htmlDetails(m.preprocessSource(pageBreaks(str)))
+	var buf bytes.Buffer
+	if err := md.Convert([]byte(src), &buf); err != nil {
+		return "", err
+	}
+	out := restoreHTMLDetails(buf.String(), tags)
+	return strings.ReplaceAll(out, "<p>"+pageBreakPlaceholder+"</p>", `<div class="page-break"></div>`), nil
+}
+
+// pageBreaks replaces the page break markers outside of code blocks by placeholders,
+// as separate paragraphs.
+func pageBreaks(content string) string {
+	lines := strings.Split(content, "\n")
+	fence := ""
+	for i, line := range lines {
+		if f := fenceMarker(line); f != "" {
+			if fence == "" {
+				fence = f
+			} else if strings.HasPrefix(f, fence) {
+				fence = ""
+			}
+			continue
+		}
+		if fence == "" && pageBreakRe.MatchString(line) {
+			lines[i] = "\n" + pageBreakPlaceholder + "\n"
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// htmlPage returns a full HTML document with the given content and navigation links.
+func htmlPage(title string, links []pageLink, content string) (string, error) {
+	var buf bytes.Buffer
+	if err := pageTemplate.Execute(&buf, page{
+		Title:   title,
+		Links:   links,
+		Content: template.HTML(content),
+	}); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// htmlSite renders all the markdown files of the directory to HTML pages, keeping
+// the directory structure, and adds an index.html page linking all of them.
+// Each page contains navigation links to all the others.
+func (m *Glow) htmlSite(ctx context.Context, dir *dagger.Directory) (*dagger.Directory, error) {
+	files, err := dir.Glob(ctx, "**/*.md")
+	if err != nil {
+		return nil, fmt.Errorf("could not list markdown files: %w", err)
+	}
+
+	site := dag.Directory()
+	for _, file := range files {
+		c, err := dir.File(file).Contents(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("could not read %s: %w", file, err)
+		}
+		if c, err = resolveIncludes(ctx, dir, file, c, nil); err != nil {
+			return nil, err
+		}
+		content, err := m.renderHTML(c)
+		if err != nil {
+			return nil, fmt.Errorf("could not render %s: %w", file, err)
+		}
+		p, err := htmlPage(file, siteLinks(file, files), content)
+		if err != nil {
+			return nil, err
+		}
+		site = site.WithNewFile(htmlName(file), p)
+	}
+
+	index, err := htmlPage("Index", siteLinks("index.html", files), "<h1>Index</h1>")
+	if err != nil {
+		return nil, err
+	}
+	return site.WithNewFile("index.html", index), nil
+}
+
+// siteLinks returns the links to all the pages, relative to the current one.
+func siteLinks(current string, files []string) []pageLink {
+	root := strings.Repeat("../", strings.Count(current, "/"))
+	links := make([]pageLink, 0, len(files))
+	for _, file := range files {
+		links = append(links, pageLink{
+			Name: file,
+			Href: root + htmlName(file),
+		})
+	}
+	return links
+}
+
+// htmlName returns the name of the HTML file rendered from a markdown file.
+func htmlName(file string) string {
+	return strings.TrimSuffix(file, path.Ext(file)) + ".html"
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Inline link, not preceded by '!' (image) or '\' (escaped), with an optional title
+var inlineLinkRe = regexp.MustCompile(`(^|[^!\\])\[([^\]]+)\]\(<?([^)\s>]+)>?(?:\s+"[^"]*")?\)`)
+
+// Markers of the hyperlinks in the markdown source: an escape sequence ending with
+// 'z', with the index of the url when opening the link, without when closing it.
+//
+// OSC 8 sequences can't be inserted before rendering: the word wrap doesn't
+// recognize them and may split them across lines. The markers are seen as
+// zero width sequences and are replaced by OSC 8 sequences once rendered.
+// The bracket is escaped so it's not parsed as markdown.
+var linkMarkerRe = regexp.MustCompile(`\x1b\[(\d*)z`)
+
+// Leading spaces of a rendered line, with the ANSI sequences styling them
+var leadingSpacesRe = regexp.MustCompile(`^(?:\x1b\[[0-9;]*m|[ \t])+`)
+
+// hyperlinks replaces the inline links of the markdown source by their text,
+// surrounded by markers to be restored as hyperlinks by restoreHyperlinks.
+// Links inside code blocks and code spans are kept as is, as well as autolinks
+// ('<https://...>' and bare urls) whose text is already the url.
+func hyperlinks(content string) (string, []string) {
+	var urls []string
+	content = replaceInline(content, func(text string) string {
+		return inlineLinkRe.ReplaceAllStringFunc(text, func(link string) string {
+			match := inlineLinkRe.FindStringSubmatch(link)
+			urls = append(urls, match[3])
+			return match[1] + "\x1b\\[" + strconv.Itoa(len(urls)-1) + "z" + match[2] + "\x1b\\[z"
+		})
+	})
+	return content, urls
+}
+
+// restoreHyperlinks replaces the markers of the rendered output by OSC 8 hyperlinks to the urls.
+// A link wrapped on several lines is closed at the end of each line and opened again
+// after the margin of the next one.
+func restoreHyperlinks(out string, urls []string) string {
+	lines := strings.Split(out, "\n")
+	url := ""
+	for i, line := range lines {
+		if url != "" {
+			margin := len(leadingSpacesRe.FindString(line))
+			line = line[:margin] + osc8(url) + line[margin:]
+		}
+		line = linkMarkerRe.ReplaceAllStringFunc(line, func(marker string) string {
+			url = ""
+			if n, err := strconv.Atoi(linkMarkerRe.FindStringSubmatch(marker)[1]); err == nil && n < len(urls) {
+				url = urls[n]
+			}
+			return osc8(url)
+		})
+		if url != "" {
+			// Closed before the padding so it can still be trimmed
+			end := len(line)
+			if loc := trailingSpacesRe.FindStringIndex(line); loc != nil {
+				end = loc[0]
+			}
+			line = line[:end] + osc8("") + line[end:]
+		}
+		lines[i] = line
+	}
+	return strings.Join(lines, "\n")
+}
+
+// osc8 returns the sequence opening a hyperlink to the url, or closing it if the url is empty.
+// BEL is used as terminator as it's the most widely supported.
+func osc8(url string) string {
+	return "\x1b]8;;" + url + "\x07"
+}
+
+// replaceInline applies replace to the inline text of the markdown source,
+// leaving code blocks and code spans untouched.
+func replaceInline(content string, replace func(string) string) string {
+	lines := strings.Split(content, "\n")
+	fence := ""
+	for i, line := range lines {
+		if f := fenceMarker(line); f != "" {
+			if fence == "" {
+				fence = f
+			} else if strings.HasPrefix(f, fence) {
+				fence = ""
+			}
+			continue
+		}
+		if fence != "" {
+			continue
+		}
+
+		// Odd parts are inside code spans
+		parts := strings.Split(line, "`")
+		for j := 0; j < len(parts); j += 2 {
+			parts[j] = replace(parts[j])
+		}
+		lines[i] = strings.Join(parts, "`")
+	}
+	return strings.Join(lines, "\n")
+}
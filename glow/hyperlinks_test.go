@@ -0,0 +1,119 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestHyperlinks(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+		urls []string
+	}{
+		{
+			name: "inline link",
+			in:   "see [docs](https://example.com/docs) now",
+			want: "see \x1b\\[0zdocs\x1b\\[z now",
+			urls: []string{"https://example.com/docs"},
+		},
+		{
+			name: "title and several links",
+			in:   "[a](u1) [b](u2 \"title\")",
+			want: "\x1b\\[0za\x1b\\[z \x1b\\[1zb\x1b\\[z",
+			urls: []string{"u1", "u2"},
+		},
+		{
+			name: "autolinks",
+			in:   "<https://example.com> and https://example.org",
+			want: "<https://example.com> and https://example.org",
+		},
+		{
+			name: "image and code",
+			in:   "![alt](a.png) `[code](x)`",
+			want: "![alt](a.png) `[code](x)`",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, urls := hyperlinks(tt.in)
+			if got != tt.want {
+				t.Errorf("hyperlinks() = %q, want %q", got, tt.want)
+			}
+			if !slices.Equal(urls, tt.urls) {
+				t.Errorf("hyperlinks() urls = %q, want %q", urls, tt.urls)
+			}
+		})
+	}
+}
+
+func TestRestoreHyperlinks(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "single line",
+			in:   "  see \x1b[0zdocs\x1b[z now  ",
+			want: "  see \x1b]8;;https://example.com\x07docs\x1b]8;;\x07 now  ",
+		},
+		{
+			name: "wrapped",
+			in:   "  see \x1b[0za long  \n  link\x1b[z now",
+			want: "  see \x1b]8;;https://example.com\x07a long\x1b]8;;\x07  \n  \x1b]8;;https://example.com\x07link\x1b]8;;\x07 now",
+		},
+		{
+			name: "unknown url",
+			in:   "\x1b[3zdocs\x1b[z",
+			want: "\x1b]8;;\x07docs\x1b]8;;\x07",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := restoreHyperlinks(tt.in, []string{"https://example.com"}); got != tt.want {
+				t.Errorf("restoreHyperlinks() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderHyperlinks(t *testing.T) {
+	m := testGlow()
+	m.Hyperlinks = true
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "link",
+			in:   "see [docs](https://example.com/docs) now",
+			want: "\nsee \x1b]8;;https://example.com/docs\x07docs\x1b]8;;\x07 now\n",
+		},
+		{
+			name: "autolinks",
+			in:   "<https://example.com>",
+			want: "\nhttps://example.com https://example.com\n",
+		},
+		{
+			name: "wrapped link",
+			in:   "some words to make the link wrap at the end of the line, more and more words [a long link text](https://example.com/wrap) end",
+			want: "\nsome words to make the link wrap at the end of the line, more and more words " +
+				"\x1b]8;;https://example.com/wrap\x07a\x1b]8;;\x07\n" +
+				"\x1b]8;;https://example.com/wrap\x07long link text\x1b]8;;\x07 end\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := m.render(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("render() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"io"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// Inline image, not preceded by '\' (escaped), with an optional title
+var inlineImageRe = regexp.MustCompile(`(^|[^\\])!\[([^\]]*)\]\(<?([^)\s>]+)>?(?:\s+"[^"]*")?\)`)
+
+// image contains the data available to the image placeholder template
+type image struct {
+	Alt string
+	URL string
+}
+
+// parseImagePlaceholder parses the image placeholder template and checks it can be executed.
+func parseImagePlaceholder(placeholder string) (*template.Template, error) {
+	tmpl, err := template.New("image").Parse(placeholder)
+	if err != nil {
+		return nil, err
+	}
+	if err := tmpl.Execute(io.Discard, image{}); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// imagePlaceholders replaces the inline images of the markdown source by the
+// placeholder template executed with their alt text and url.
+// Images inside code blocks and code spans are kept as is.
+func imagePlaceholders(content string, tmpl *template.Template) string {
+	return replaceInline(content, func(text string) string {
+		return inlineImageRe.ReplaceAllStringFunc(text, func(img string) string {
+			match := inlineImageRe.FindStringSubmatch(img)
+			var b strings.Builder
+			if err := tmpl.Execute(&b, image{Alt: match[2], URL: match[3]}); err != nil {
+				return img
+			}
+			return match[1] + b.String()
+		})
+	})
+}
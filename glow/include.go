@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"dagger/glow/internal/dagger"
+)
+
+// Maximum depth of nested includes
+const maxIncludeDepth = 10
+
+// Include directive, alone on its line: '{{include: path/to/file.md}}'
+var includeRe = regexp.MustCompile(`^\s*\{\{\s*include:\s*(\S+?)\s*\}\}\s*$`)
+
+// resolveIncludes replaces the include directives of the markdown source by the
+// contents of the referenced files, recursively.
+// Paths are relative to the file containing the directive, file being the path of
+// the content in the directory. Directives inside code blocks are kept as is.
+func resolveIncludes(ctx context.Context, dir *dagger.Directory, file, content string, parents []string) (string, error) {
+	chain := append(slices.Clone(parents), file)
+	if len(chain) > maxIncludeDepth {
+		return "", fmt.Errorf("too many nested includes (max %d): %s", maxIncludeDepth, strings.Join(chain, " -> "))
+	}
+
+	lines := strings.Split(content, "\n")
+	fence := ""
+	for i, line := range lines {
+		if f := fenceMarker(line); f != "" {
+			if fence == "" {
+				fence = f
+			} else if strings.HasPrefix(f, fence) {
+				fence = ""
+			}
+			continue
+		}
+		match := includeRe.FindStringSubmatch(line)
+		if fence != "" || match == nil {
+			continue
+		}
+
+		target := path.Join(path.Dir(file), match[1])
+		if slices.Contains(chain, target) {
+			return "", fmt.Errorf("include cycle: %s -> %s", strings.Join(chain, " -> "), target)
+		}
+		c, err := dir.File(target).Contents(ctx)
+		if err != nil {
+			return "", fmt.Errorf("could not include %s in %s: %w", target, file, err)
+		}
+		if c, err = resolveIncludes(ctx, dir, target, c, chain); err != nil {
+			return "", err
+		}
+		lines[i] = strings.TrimRight(c, "\n")
+	}
+	return strings.Join(lines, "\n"), nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2/lexers"
+)
+
+// parseLexerAliases parses the 'label=lexer' aliases of code block languages.
+// The lexers must be known by chroma.
+func parseLexerAliases(aliases []string) (map[string]string, error) {
+	m := map[string]string{}
+	for _, alias := range aliases {
+		label, lexer, found := strings.Cut(alias, "=")
+		label, lexer = strings.TrimSpace(label), strings.TrimSpace(lexer)
+		if !found || label == "" || lexer == "" {
+			return nil, fmt.Errorf("invalid lexer alias %q, expected 'label=lexer'", alias)
+		}
+		if lexers.Get(lexer) == nil {
+			return nil, fmt.Errorf("unknown lexer %q for label %q", lexer, label)
+		}
+		m[label] = lexer
+	}
+	return m, nil
+}
+
+// lexerAliases replaces the language of the code blocks by the lexer it's an alias of,
+// keeping the rest of the info string.
+func lexerAliases(content string, aliases map[string]string) string {
+	lines := strings.Split(content, "\n")
+	fence := ""
+	for i, line := range lines {
+		f := fenceMarker(line)
+		if f == "" {
+			continue
+		}
+		if fence != "" {
+			if strings.HasPrefix(f, fence) {
+				fence = ""
+			}
+			continue
+		}
+		fence = f
+
+		start := strings.Index(line, f) + len(f)
+		info := strings.TrimLeft(line[start:], " ")
+		lang, rest, _ := strings.Cut(info, " ")
+		if lexer, ok := aliases[lang]; ok {
+			lines[i] = line[:start] + lexer
+			if rest != "" {
+				lines[i] += " " + rest
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
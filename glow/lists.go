@@ -0,0 +1,68 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+var (
+	// List item: indentation, marker, spacing and contents
+	listItemRe = regexp.MustCompile(`^(\s*)([-*+]|\d{1,9}[.)])(\s+)(.*)$`)
+	// Thematic break, '---', '* * *' or '___'
+	thematicBreakRe = regexp.MustCompile(`^\s*(?:(?:-\s*){3,}|(?:\*\s*){3,}|(?:_\s*){3,})$`)
+	// Task list item checkbox
+	taskRe = regexp.MustCompile(`^\[[ xX]\](?:\s|$)`)
+)
+
+// listBullets prefixes the contents of the unordered list items of the markdown source
+// with the bullet of their nesting level, the last bullet being used for the deeper
+// levels. Task list items are kept as is, as they are rendered with a checkbox.
+func listBullets(content string, bullets []string) string {
+	lines := strings.Split(content, "\n")
+	fence := ""
+	// Column of the contents of the enclosing list items
+	var items []int
+	for i, line := range lines {
+		if f := fenceMarker(line); f != "" {
+			if fence == "" {
+				fence = f
+			} else if strings.HasPrefix(f, fence) {
+				fence = ""
+			}
+			continue
+		}
+		if fence != "" || strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		indent := len(line) - len(strings.TrimLeft(line, " \t"))
+		for len(items) > 0 && indent < items[len(items)-1] {
+			items = items[:len(items)-1]
+		}
+		match := listItemRe.FindStringSubmatch(line)
+		if match == nil || thematicBreakRe.MatchString(line) {
+			continue
+		}
+
+		level := len(items)
+		items = append(items, len(match[1])+len(match[2])+len(match[3]))
+		if strings.ContainsAny(match[2], "-*+") && !taskRe.MatchString(match[4]) {
+			bullet := bullets[min(level, len(bullets)-1)]
+			lines[i] = match[1] + match[2] + match[3] + escapePunctuation(bullet) + " " + match[4]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// escapePunctuation escapes the ASCII punctuation characters so they are not parsed as markdown.
+func escapePunctuation(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r <= unicode.MaxASCII && (unicode.IsPunct(r) || unicode.IsSymbol(r)) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
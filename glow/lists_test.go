@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestListBullets(t *testing.T) {
+	bullets := []string{"•", "◦", "-"}
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "three levels",
+			in:   "- one\n  - two\n    * three\n      + four\n- back",
+			want: "- • one\n  - ◦ two\n    * \\- three\n      + \\- four\n- • back",
+		},
+		{
+			name: "ordered parent",
+			in:   "1. one\n   - two",
+			want: "1. one\n   - ◦ two",
+		},
+		{
+			name: "tasks and thematic breaks",
+			in:   "- [ ] task\n- [x] done\n\n- - -",
+			want: "- [ ] task\n- [x] done\n\n- - -",
+		},
+		{
+			name: "code blocks",
+			in:   "```\n- code\n```\n- item",
+			want: "```\n- code\n```\n- • item",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := listBullets(tt.in, bullets); got != tt.want {
+				t.Errorf("listBullets() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderListBullets(t *testing.T) {
+	m := testGlow()
+	m.Bullets = []string{"•", "◦", "▪"}
+	out, err := m.render("- one\n  - two\n    - three\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var bullets []string
+	for _, line := range strings.Split(out, "\n") {
+		if fields := strings.Fields(line); len(fields) == 2 {
+			bullets = append(bullets, fields[0])
+		}
+	}
+	if strings.Join(bullets, " ") != "• ◦ ▪" {
+		t.Errorf("render() = %q, want the bullets •, ◦ and ▪ by level", out)
+	}
+}
@@ -3,29 +3,661 @@
 package main
 
 import (
+	"cmp"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
 
 	"dagger/glow/internal/dagger"
-	"github.com/charmbracelet/glamour"
 )
 
-type Glow struct{}
+// Number starting the name of a chapter file
+var chapterNumberRe = regexp.MustCompile(`^\d+`)
+
+// Version of charmbracelet/freeze used to take screenshots of the rendered markdown
+const freezeVersion = "v0.2.2"
+
+type Glow struct {
+	// Markdown flavor used to parse the input, 'gfm' or 'commonmark'
+	Flavor string
+	// Number of columns left empty on the left of the rendered output
+	MarginLeft int
+	// Number of columns left empty on the right of the rendered output
+	MarginRight int
+	// Render the pandoc title block ('% title', '% authors', '% date') as a heading
+	PandocTitleBlock bool
+	// Make links clickable with OSC 8 hyperlinks instead of printing their url
+	Hyperlinks bool
+	// Template of the text replacing images, with {{.Alt}} and {{.URL}} placeholders
+	ImagePlaceholder string
+	// Show the body of the collapsible '<details>' sections
+	ExpandDetails bool
+	// Render LaTeX math expressions as code
+	Math bool
+	// Color profile of the rendered output, 'truecolor', '256', 'ansi' or 'none'
+	ColorProfile string
+	// Render footnotes as superscript numbers, with the definitions listed at the end
+	Footnotes bool
+	// Trim the trailing whitespace of the rendered lines and the trailing blank lines
+	Trim bool
+	// Output the contents of the '```ansi' code blocks verbatim
+	AllowAnsi bool
+	// Remove the HTML comments before rendering
+	StripComments bool
+	// Character prefixing the lines of blockquotes, repeated for nested ones
+	BlockquotePrefix string
+	// Color of the blockquote prefix, an ANSI color number or a '#rrggbb' hex color
+	BlockquoteColor string
+	// Reduce the vertical spacing around the document and the headings
+	Compact bool
+	// Color of the inline code text, an ANSI color number or a '#rrggbb' hex color
+	CodeColor string
+	// Background color of the inline code, an ANSI color number or a '#rrggbb' hex color
+	CodeBackground string
+	// Aliases of the code block languages, as 'label=lexer'
+	LexerAliases []string
+	// Render the tables wider than the output as lists
+	NarrowTables bool
+	// Number of levels the headings are shifted down by
+	Demote int
+	// Bullets of the unordered list items, by nesting level
+	Bullets []string
+	// Characters following the number of the ordered list items
+	EnumerationSuffix string
+	// Style of the headings, 'styled' or 'hashes'
+	HeadingStyle string
+}
+
+func New(
+	// Markdown flavor used to parse the input, 'gfm' or 'commonmark'.
+	// 'commonmark' disables all GitHub extensions (tables, strikethrough, autolinks, task lists)
+	// +optional
+	// +default="gfm"
+	flavor string,
+	// Number of columns left empty on the left of the rendered output, 0 to remove the margin
+	// +optional
+	// +default=2
+	marginLeft int,
+	// Number of columns left empty on the right of the rendered output, 0 to remove the margin
+	// +optional
+	// +default=0
+	marginRight int,
+	// Render the pandoc title block ('% title', '% authors', '% date') starting the document as a heading
+	// +optional
+	pandocMeta bool,
+	// Make links clickable in supporting terminals, using OSC 8 hyperlinks instead of printing their url
+	// +optional
+	hyperlinks bool,
+	// Template of the text replacing images, with {{.Alt}} and {{.URL}} placeholders, e.g. '🖼 {{.Alt}} ({{.URL}})'.
+	// Default to glamour's rendering of images
+	// +optional
+	imagePlaceholder string,
+	// Show the body of the collapsible '<details>' sections instead of collapsing them behind an '[expand]' marker
+	// +optional
+	expandDetails bool,
+	// Render LaTeX math expressions ('$...$' and '$$...$$') as code, so they are legible instead of raw markup
+	// +optional
+	math bool,
+	// Color profile of the rendered output, 'truecolor', '256', 'ansi' or 'none' to remove all colors.
+	// Forced to 'none' by no-color
+	// +optional
+	// +default="truecolor"
+	colorProfile string,
+	// Remove all colors, as the 'none' color profile. The module can't read the environment of the host:
+	// set it when NO_COLOR is set, e.g. with '${NO_COLOR:+--no-color}' in a shell
+	// +optional
+	noColor bool,
+	// Render footnotes ('[^1]') as superscript numbers, with the definitions listed at the end of the document.
+	// Only used with the 'gfm' flavor
+	// +optional
+	// +default=true
+	footnotes bool,
+	// Trim the trailing whitespace of the rendered lines and the trailing blank lines, for clean diffs of captured outputs.
+	// Disable it to keep the padding of the rendered lines
+	// +optional
+	// +default=true
+	trim bool,
+	// Output the contents of the '```ansi' code blocks verbatim, keeping their escape sequences, to display ANSI art.
+	// Only enable it for trusted inputs, as escape sequences can alter the terminal
+	// +optional
+	allowAnsi bool,
+	// Remove the HTML comments ('<!-- ... -->') before rendering, except in code blocks and code spans
+	// +optional
+	// +default=true
+	stripComments bool,
+	// Character prefixing the lines of blockquotes, repeated for each level of nested blockquotes
+	// +optional
+	// +default="│"
+	blockquotePrefix string,
+	// Color of the blockquote prefix, an ANSI color number (e.g. '244') or a '#rrggbb' hex color.
+	// Default to the color of the text
+	// +optional
+	blockquoteColor string,
+	// Reduce the vertical spacing, removing the blank lines around the document and after the headings
+	// +optional
+	compact bool,
+	// Color of the inline code text, an ANSI color number (e.g. '203') or a '#rrggbb' hex color.
+	// Code blocks are not affected
+	// +optional
+	codeColor string,
+	// Background color of the inline code, an ANSI color number (e.g. '236') or a '#rrggbb' hex color.
+	// Code blocks are not affected
+	// +optional
+	codeBackground string,
+	// Aliases of the code block languages to the chroma lexers highlighting them, as 'label=lexer' (e.g. 'hcl=terraform')
+	// +optional
+	lexerAliases []string,
+	// Render the tables wider than the output as lists, each row listing its 'header: value' pairs, instead of truncating their cells
+	// +optional
+	narrowTables bool,
+	// Number of levels the headings are shifted down by, from 0 to 5, to embed the document in a larger one (e.g. 1 renders '# title' as '## title').
+	// Levels are capped at 6
+	// +optional
+	demote int,
+	// Bullets of the unordered list items, by nesting level (e.g. '•', '◦', '▪'), the last one being used for the deeper levels.
+	// Default to glamour's bullet for all the levels
+	// +optional
+	bullets []string,
+	// Characters following the number of the ordered list items, e.g. ')' to render '1)'
+	// +optional
+	// +default="."
+	enumerationSuffix string,
+	// Style of the headings, 'styled' for glamour's rendering or 'hashes' to keep the '#' markers of all the levels, colored without the highlighted title
+	// +optional
+	// +default="styled"
+	headingStyle string,
+) (*Glow, error) {
+	switch flavor {
+	case "gfm", "commonmark":
+	default:
+		return nil, fmt.Errorf("unknown flavor %q, must be 'gfm' or 'commonmark'", flavor)
+	}
+	if marginLeft < 0 || marginRight < 0 {
+		return nil, fmt.Errorf("margins must be positive or zero")
+	}
+	if _, ok := colorProfiles[colorProfile]; !ok {
+		return nil, fmt.Errorf("unknown color profile %q, must be 'truecolor', '256', 'ansi' or 'none'", colorProfile)
+	}
+	if noColor {
+		colorProfile = "none"
+	}
+	for name, color := range map[string]string{
+		"blockquote":      blockquoteColor,
+		"code":            codeColor,
+		"code background": codeBackground,
+	} {
+		if color != "" && !validColor(color) {
+			return nil, fmt.Errorf("invalid %s color %q, must be an ANSI color number or a '#rrggbb' hex color", name, color)
+		}
+	}
+	if _, err := parseImagePlaceholder(imagePlaceholder); err != nil {
+		return nil, fmt.Errorf("invalid image placeholder: %w", err)
+	}
+	for _, bullet := range bullets {
+		if strings.TrimSpace(bullet) == "" || strings.Contains(bullet, "\n") {
+			return nil, fmt.Errorf("invalid bullet %q, must be visible characters on a single line", bullet)
+		}
+	}
+	if strings.Contains(enumerationSuffix, "\n") {
+		return nil, fmt.Errorf("invalid enumeration suffix %q, must be on a single line", enumerationSuffix)
+	}
+	switch headingStyle {
+	case "styled", "hashes":
+	default:
+		return nil, fmt.Errorf("unknown heading style %q, must be 'styled' or 'hashes'", headingStyle)
+	}
+	if demote < 0 || demote > 5 {
+		return nil, fmt.Errorf("demote must be between 0 and 5")
+	}
+	if _, err := parseLexerAliases(lexerAliases); err != nil {
+		return nil, err
+	}
+	return &Glow{
+		Flavor:            flavor,
+		MarginLeft:        marginLeft,
+		MarginRight:       marginRight,
+		PandocTitleBlock:  pandocMeta,
+		Hyperlinks:        hyperlinks,
+		ImagePlaceholder:  imagePlaceholder,
+		ExpandDetails:     expandDetails,
+		Math:              math,
+		ColorProfile:      colorProfile,
+		Footnotes:         footnotes,
+		Trim:              trim,
+		AllowAnsi:         allowAnsi,
+		StripComments:     stripComments,
+		BlockquotePrefix:  blockquotePrefix,
+		BlockquoteColor:   blockquoteColor,
+		Compact:           compact,
+		CodeColor:         codeColor,
+		CodeBackground:    codeBackground,
+		LexerAliases:      lexerAliases,
+		NarrowTables:      narrowTables,
+		Demote:            demote,
+		Bullets:           bullets,
+		EnumerationSuffix: enumerationSuffix,
+		HeadingStyle:      headingStyle,
+	}, nil
+}
 
 // Render a markdown input string to be displayed on a terminal.
 func (m *Glow) DisplayMarkdown(str string) (string, error) {
-	return glamour.Render(str, "dark")
+	return m.render(str)
+}
+
+// Get the width of a markdown input string once rendered.
+//
+// The width is the number of columns of the longest line, ignoring ANSI sequences
+// and the trailing padding, and taking wide characters into account.
+func (m *Glow) RenderedWidth(ctx context.Context, content string) (int, error) {
+	out, err := m.render(content)
+	if err != nil {
+		return 0, err
+	}
+	return width(out), nil
+}
+
+// Check that a markdown input string, once rendered, fits in a number of columns.
+//
+// Widths are computed as for RenderedWidth. The error reports the first line
+// wider than the limit.
+func (m *Glow) FitsWidth(ctx context.Context, content string, maxWidth int) (bool, error) {
+	if maxWidth < 1 {
+		return false, fmt.Errorf("invalid max width %d, must be positive", maxWidth)
+	}
+	out, err := m.render(content)
+	if err != nil {
+		return false, err
+	}
+	for i, line := range strings.Split(out, "\n") {
+		if w := lineWidth(line); w > maxWidth {
+			return false, fmt.Errorf("line %d is %d columns wide, more than %d", i+1, w, maxWidth)
+		}
+	}
+	return true, nil
 }
 
 // Print readme file in the terminal
+//
+// If the file has no top-level heading, its name without extension is used as title.
+//
+// When the directory containing the file is given, each '{{include: path}}' line
+// is replaced by the contents of the file at this path, relative to the including file.
 func (m *Glow) ReadMe(
 	ctx context.Context,
-// +defaultPath="README.md"
+	// +defaultPath="README.md"
 	file dagger.File,
+	// Title rendered as a top-level heading before the file contents
+	// +optional
+	title string,
+	// Directory containing the file, used to resolve the '{{include: path}}' directives
+	// +optional
+	dir *dagger.Directory,
 ) (string, error) {
 	c, err := file.Contents(ctx)
 	if err != nil {
 		return "", fmt.Errorf("could not read README.md: %w", err)
 	}
+	if dir != nil {
+		name, err := file.Name(ctx)
+		if err != nil {
+			return "", fmt.Errorf("could not get file name: %w", err)
+		}
+		if c, err = resolveIncludes(ctx, dir, name, c, nil); err != nil {
+			return "", err
+		}
+	}
+	if title == "" && !m.hasTitle(c) {
+		name, err := file.Name(ctx)
+		if err != nil {
+			return "", fmt.Errorf("could not get file name: %w", err)
+		}
+		title = strings.TrimSuffix(name, path.Ext(name))
+	}
+	if title != "" {
+		c = "# " + title + "\n\n" + c
+	}
 	return m.DisplayMarkdown(c)
 }
+
+// Render a markdown file framed in a box, with the title in the top border.
+func (m *Glow) Boxed(
+	ctx context.Context,
+	file dagger.File,
+	// Title displayed in the top border, default to the file name
+	// +optional
+	title string,
+) (string, error) {
+	c, err := file.Contents(ctx)
+	if err != nil {
+		return "", fmt.Errorf("could not read file: %w", err)
+	}
+	if title == "" {
+		if title, err = file.Name(ctx); err != nil {
+			return "", fmt.Errorf("could not get file name: %w", err)
+		}
+	}
+	out, err := m.render(c)
+	if err != nil {
+		return "", err
+	}
+	return box(out, title), nil
+}
+
+// Render a range of lines of a markdown file.
+//
+// Lines are numbered from 1, the range includes both the start and the end lines.
+func (m *Glow) Lines(ctx context.Context, file dagger.File, start int, end int) (string, error) {
+	c, err := file.Contents(ctx)
+	if err != nil {
+		return "", fmt.Errorf("could not read file: %w", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(c, "\n"), "\n")
+	if start < 1 || start > end {
+		return "", fmt.Errorf("invalid line range %d-%d, start must be between 1 and end", start, end)
+	}
+	if end > len(lines) {
+		return "", fmt.Errorf("invalid line range %d-%d, the file has %d lines", start, end, len(lines))
+	}
+	return m.render(strings.Join(lines[start-1:end], "\n"))
+}
+
+// Take a PNG screenshot of a markdown file rendered in a terminal.
+func (m *Glow) Screenshot(ctx context.Context, file dagger.File) (*dagger.File, error) {
+	c, err := file.Contents(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not read file: %w", err)
+	}
+	out, err := m.render(c)
+	if err != nil {
+		return nil, err
+	}
+	return dag.Container().
+		From("golang:1.23.6").
+		WithExec([]string{"go", "install", "github.com/charmbracelet/freeze@" + freezeVersion}).
+		WithWorkdir("/work").
+		WithNewFile("render.ansi", out).
+		WithExec([]string{"freeze", "--execute", "cat render.ansi", "--output", "render.png"}).
+		File("render.png"), nil
+}
+
+// Render a markdown file to a file containing the ANSI output, named after it with the '.ansi' extension.
+//
+// The output keeps its colors, using the truecolor profile when the colors are
+// disabled, and ends with a newline. It must be viewed with an ANSI-aware viewer,
+// like 'cat' in a terminal or 'less -R'.
+func (m *Glow) RenderToAnsiFile(ctx context.Context, file dagger.File) (*dagger.File, error) {
+	c, err := file.Contents(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not read file: %w", err)
+	}
+	name, err := file.Name(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not get file name: %w", err)
+	}
+
+	g := *m
+	if g.ColorProfile == "none" {
+		g.ColorProfile = "truecolor"
+	}
+	out, err := g.render(c)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(out, "\n") {
+		out += "\n"
+	}
+	name = strings.TrimSuffix(name, path.Ext(name)) + ".ansi"
+	return dag.Directory().WithNewFile(name, out).File(name), nil
+}
+
+// Render release notes from a list of commit messages.
+//
+// The commits are grouped by conventional commit type ('feat', 'fix', 'chore'...),
+// the ones without a known type being listed under 'Other'. Only the subject,
+// the first line of each message, is used.
+func (m *Glow) RenderCommits(ctx context.Context, commits []string) (string, error) {
+	return m.render(releaseNotes(commits))
+}
+
+// Render the latest version section of a changelog.
+//
+// The section starts at the first heading of the selected level looking like a
+// version (e.g. '## v1.2.0' or '## [1.2.0] - 2024-01-01') and ends at the next
+// heading of the same or a higher level.
+func (m *Glow) LatestChangelog(
+	ctx context.Context,
+	// +defaultPath="CHANGELOG.md"
+	file dagger.File,
+	// Level of the version headings
+	// +optional
+	// +default=2
+	level int,
+	// Skip the 'Unreleased' section, if any
+	// +optional
+	// +default=true
+	skipUnreleased bool,
+) (string, error) {
+	if level < 1 || level > 6 {
+		return "", fmt.Errorf("heading level must be between 1 and 6, got %d", level)
+	}
+	c, err := file.Contents(ctx)
+	if err != nil {
+		return "", fmt.Errorf("could not read changelog: %w", err)
+	}
+	section, ok := latestChangelogSection(c, level, skipUnreleased)
+	if !ok {
+		return "", fmt.Errorf("no version heading of level %d found", level)
+	}
+	return m.render(section)
+}
+
+// Render multiple markdown files, one section per file in the given order.
+//
+// Each section starts with a separator showing the file name. Files that
+// could not be read or rendered are reported in a combined error while
+// the others are still rendered.
+func (m *Glow) RenderFiles(ctx context.Context, files []dagger.File) (string, error) {
+	var (
+		b    strings.Builder
+		errs []error
+	)
+	for i, file := range files {
+		name, err := file.Name(ctx)
+		if err != nil {
+			name = fmt.Sprintf("file #%d", i+1)
+		}
+		b.WriteString(separator(name))
+
+		c, err := file.Contents(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not read %s: %w", name, err))
+			continue
+		}
+		out, err := m.render(c)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not render %s: %w", name, err))
+			continue
+		}
+		b.WriteString(out)
+	}
+	return b.String(), errors.Join(errs...)
+}
+
+// Render the chapters of a document split across the files of a directory as one document.
+//
+// The chapters are the files matching the pattern whose name starts with a number,
+// e.g. '01-intro.md' and '02-usage.md', sorted by this number. The other files are
+// skipped. '{{include: path}}' lines are replaced by the contents of the file at
+// this path, relative to the including file.
+func (m *Glow) RenderNumbered(
+	ctx context.Context,
+	dir *dagger.Directory,
+	// Glob of the chapter files, relative to the directory
+	// +optional
+	// +default="[0-9]*.md"
+	pattern string,
+) (string, error) {
+	files, err := dir.Glob(ctx, pattern)
+	if err != nil {
+		return "", fmt.Errorf("could not list the files matching %q: %w", pattern, err)
+	}
+
+	type chapter struct {
+		file   string
+		number int
+	}
+	var chapters []chapter
+	for _, file := range files {
+		digits := chapterNumberRe.FindString(path.Base(file))
+		if digits == "" {
+			continue
+		}
+		n, err := strconv.Atoi(digits)
+		if err != nil {
+			return "", fmt.Errorf("invalid chapter number of %s: %w", file, err)
+		}
+		chapters = append(chapters, chapter{file: file, number: n})
+	}
+	if len(chapters) == 0 {
+		return "", fmt.Errorf("no numbered file matching %q", pattern)
+	}
+	slices.SortStableFunc(chapters, func(a, b chapter) int {
+		return cmp.Or(cmp.Compare(a.number, b.number), strings.Compare(a.file, b.file))
+	})
+
+	contents := make([]string, 0, len(chapters))
+	for _, c := range chapters {
+		content, err := dir.File(c.file).Contents(ctx)
+		if err != nil {
+			return "", fmt.Errorf("could not read %s: %w", c.file, err)
+		}
+		if content, err = resolveIncludes(ctx, dir, c.file, content, nil); err != nil {
+			return "", err
+		}
+		contents = append(contents, strings.TrimRight(content, "\n"))
+	}
+	return m.render(strings.Join(contents, "\n\n"))
+}
+
+// Serve the markdown files of a directory as a website.
+//
+// Each markdown file is rendered to an HTML page, with navigation between
+// all the pages. Use 'dagger call serve --dir=. up' to open it locally.
+// '{{include: path}}' lines are replaced by the contents of the file at this
+// path, relative to the including file.
+func (m *Glow) Serve(ctx context.Context, dir *dagger.Directory) (*dagger.Service, error) {
+	site, err := m.htmlSite(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+	return dag.Container().
+		From("python:3.13-alpine").
+		WithDirectory("/srv", site).
+		WithWorkdir("/srv").
+		WithExposedPort(8080).
+		AsService(dagger.ContainerAsServiceOpts{
+			Args: []string{"python", "-m", "http.server", "8080"},
+		}), nil
+}
+
+// Render the markdown files of a directory to HTML files.
+//
+// The directory structure is kept, each '.md' file being rendered to a '.html'
+// one with navigation between all the pages, and an index.html page links all
+// of them. This is the static site served by serve.
+func (m *Glow) RenderDirToHTML(ctx context.Context, dir *dagger.Directory) (*dagger.Directory, error) {
+	return m.htmlSite(ctx, dir)
+}
+
+// Validate the structure of a markdown file.
+//
+// The report lists tables with inconsistent column counts, unclosed code
+// fences and headings skipping levels. It is empty when no issue is found.
+func (m *Glow) Validate(
+	ctx context.Context,
+	// +defaultPath="README.md"
+	file dagger.File,
+) (string, error) {
+	c, err := file.Contents(ctx)
+	if err != nil {
+		return "", fmt.Errorf("could not read file: %w", err)
+	}
+	issues := validate(c)
+	if len(issues) == 0 {
+		return "", nil
+	}
+	return strings.Join(issues, "\n") + "\n", nil
+}
+
+// Render a file if it contains markdown, otherwise return its raw contents.
+//
+// Files with a markdown extension are always rendered. For other files, the
+// contents is rendered only if at least two different markdown constructs
+// (headings, code fences, lists, blockquotes, links, tables, bold text or
+// code spans) are found on different lines.
+func (m *Glow) RenderIfMarkdown(ctx context.Context, file dagger.File) (string, error) {
+	c, err := file.Contents(ctx)
+	if err != nil {
+		return "", fmt.Errorf("could not read file: %w", err)
+	}
+	name, err := file.Name(ctx)
+	if err != nil {
+		return "", fmt.Errorf("could not get file name: %w", err)
+	}
+	if !isMarkdown(name, c) {
+		return c, nil
+	}
+	return m.render(c)
+}
+
+// Get the metadata of the pandoc title block ('% title', '% authors', '% date') of a markdown file.
+func (m *Glow) PandocMetadata(ctx context.Context, file dagger.File) (*PandocMetadata, error) {
+	c, err := file.Contents(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not read file: %w", err)
+	}
+	meta, _, ok := parsePandocTitleBlock(c)
+	if !ok {
+		return &PandocMetadata{}, nil
+	}
+	return meta, nil
+}
+
+// Get the outline of a markdown file as a JSON array of headings.
+//
+// Each heading has its level, its text without markup and its anchor, generated
+// the same way as GitHub does.
+func (m *Glow) Outline(ctx context.Context, file dagger.File) (string, error) {
+	c, err := file.Contents(ctx)
+	if err != nil {
+		return "", fmt.Errorf("could not read file: %w", err)
+	}
+	out, err := json.MarshalIndent(m.outline(c), "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("could not encode outline: %w", err)
+	}
+	return string(out), nil
+}
+
+// Describe the contents of a markdown file as JSON.
+//
+// The description contains the size in bytes, the number of lines, headings,
+// links, images and code blocks, and the language most used by the code blocks.
+func (m *Glow) Describe(ctx context.Context, file dagger.File) (string, error) {
+	c, err := file.Contents(ctx)
+	if err != nil {
+		return "", fmt.Errorf("could not read file: %w", err)
+	}
+	out, err := json.MarshalIndent(m.describe(c), "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("could not encode description: %w", err)
+	}
+	return string(out), nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Inline math expression: the opening '$' is not escaped and followed by a non-space,
+// the closing one is preceded by a non-space and not followed by a digit, so amounts
+// like '$5 and $10' are not matched.
+var inlineMathRe = regexp.MustCompile(`(^|[^\\$])\$([^\s$](?:[^$]*?[^\s$\\])?)\$([^\d$]|$)`)
+
+// mathCode replaces the LaTeX math expressions of the markdown source so they are legible
+// in a terminal: blocks ('$$...$$') become 'math' code blocks and inline expressions
+// ('$...$') become code spans.
+func mathCode(content string) string {
+	return replaceInline(mathBlocks(content), func(text string) string {
+		return inlineMathRe.ReplaceAllString(text, "$1`$2`$3")
+	})
+}
+
+// mathBlocks replaces the '$$...$$' blocks, outside of code blocks, by 'math' code blocks.
+// Blocks not closed are kept as is.
+func mathBlocks(content string) string {
+	lines := strings.Split(content, "\n")
+	var out []string
+	fence := ""
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if f := fenceMarker(line); f != "" {
+			if fence == "" {
+				fence = f
+			} else if strings.HasPrefix(f, fence) {
+				fence = ""
+			}
+		}
+		trimmed := strings.TrimSpace(line)
+		if fence != "" || !strings.HasPrefix(trimmed, "$$") {
+			out = append(out, line)
+			continue
+		}
+
+		// The expression may be on the opening line, the closing one, or both
+		end := i
+		if len(trimmed) < 4 || !strings.HasSuffix(trimmed, "$$") {
+			for end = i + 1; end < len(lines) && !strings.HasSuffix(strings.TrimSpace(lines[end]), "$$"); end++ {
+			}
+			if end == len(lines) {
+				out = append(out, line)
+				continue
+			}
+		}
+		expr := strings.TrimSpace(strings.Join(append([]string{trimmed}, lines[i+1:end+1]...), "\n"))
+		expr = strings.TrimSuffix(strings.TrimPrefix(expr, "$$"), "$$")
+
+		out = append(out, "```math", strings.TrimSpace(expr), "```")
+		i = end
+	}
+	return strings.Join(out, "\n")
+}
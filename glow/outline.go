@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// heading is an entry of the outline of a document
+type heading struct {
+	Level  int    `json:"level"`
+	Text   string `json:"text"`
+	Anchor string `json:"anchor"`
+}
+
+// outline returns the headings of the markdown source, in order, with the anchors
+// GitHub generates for them.
+func (m *Glow) outline(content string) []heading {
+	source := []byte(content)
+	doc := goldmark.New(goldmark.WithExtensions(m.extensions()...)).Parser().Parse(text.NewReader(source))
+
+	headings := []heading{}
+	slugs := map[string]int{}
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		h, ok := n.(*ast.Heading)
+		if !ok || !entering {
+			return ast.WalkContinue, nil
+		}
+		title := inlineText(h, source)
+		anchor := githubSlug(title)
+		if count := slugs[anchor]; count > 0 {
+			slugs[anchor]++
+			anchor = fmt.Sprintf("%s-%d", anchor, count)
+		} else {
+			slugs[anchor] = 1
+		}
+		headings = append(headings, heading{Level: h.Level, Text: title, Anchor: anchor})
+		return ast.WalkSkipChildren, nil
+	})
+	return headings
+}
+
+// hasTitle returns whether the markdown source has a top-level heading.
+func (m *Glow) hasTitle(content string) bool {
+	for _, h := range m.outline(content) {
+		if h.Level == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// inlineText returns the text of the inline children of a node, without markup.
+func inlineText(n ast.Node, source []byte) string {
+	var b strings.Builder
+	for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+		switch c := c.(type) {
+		case *ast.Text:
+			b.Write(c.Segment.Value(source))
+			if c.SoftLineBreak() {
+				b.WriteByte(' ')
+			}
+		case *ast.String:
+			b.Write(c.Value)
+		default:
+			b.WriteString(inlineText(c, source))
+		}
+	}
+	return b.String()
+}
+
+// githubSlug returns the anchor of a heading the way GitHub generates it: lower case,
+// punctuation removed and spaces replaced by hyphens.
+func githubSlug(title string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(title)) {
+		switch {
+		case r == ' ':
+			b.WriteRune('-')
+		case r == '-' || r == '_' || unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.Is(unicode.Mn, r):
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"strings"
+)
+
+// Metadata of a pandoc title block
+type PandocMetadata struct {
+	// Title of the document
+	Title string
+	// Authors of the document
+	Authors []string
+	// Date of the document
+	Date string
+}
+
+// parsePandocTitleBlock parses the pandoc title block starting the content, if any,
+// and returns the content without it.
+//
+// The title block is made of up to three lines starting with '%': the title,
+// the authors separated by ';' and the date. Lines starting with a space continue
+// the previous one.
+func parsePandocTitleBlock(content string) (*PandocMetadata, string, bool) {
+	lines := strings.Split(content, "\n")
+	var fields []string
+	i := 0
+loop:
+	for ; i < len(lines) && len(fields) < 3; i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "%"):
+			fields = append(fields, strings.TrimSpace(strings.TrimPrefix(line, "%")))
+		case len(fields) > 0 && strings.HasPrefix(line, " ") && strings.TrimSpace(line) != "":
+			fields[len(fields)-1] += " " + strings.TrimSpace(line)
+		default:
+			break loop
+		}
+	}
+	if len(fields) == 0 {
+		return nil, content, false
+	}
+
+	meta := &PandocMetadata{Title: fields[0]}
+	if len(fields) > 1 {
+		for _, author := range strings.Split(fields[1], ";") {
+			if author = strings.TrimSpace(author); author != "" {
+				meta.Authors = append(meta.Authors, author)
+			}
+		}
+	}
+	if len(fields) > 2 {
+		meta.Date = fields[2]
+	}
+	return meta, strings.Join(lines[i:], "\n"), true
+}
+
+// pandocTitleBlockToMarkdown replaces the pandoc title block by a heading with the
+// title, followed by the authors and date.
+func pandocTitleBlockToMarkdown(content string) string {
+	meta, body, ok := parsePandocTitleBlock(content)
+	if !ok {
+		return content
+	}
+
+	var b strings.Builder
+	if meta.Title != "" {
+		b.WriteString("# " + meta.Title + "\n\n")
+	}
+	byline := strings.Join(meta.Authors, ", ")
+	if meta.Date != "" {
+		if byline != "" {
+			byline += " — "
+		}
+		byline += meta.Date
+	}
+	if byline != "" {
+		b.WriteString("*" + byline + "*\n\n")
+	}
+	b.WriteString(body)
+	return b.String()
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// Link reference definition, '[label]: url "title"'
+	linkDefinitionRe = regexp.MustCompile(`^ {0,3}\[([^\]^][^\]]*)\]:\s*<?([^\s>]+)>?(?:\s+(?:"[^"]*"|'[^']*'|\([^)]*\)))?\s*$`)
+	// Reference link, full '[text][label]', collapsed '[text][]' or shortcut '[text]'
+	referenceLinkRe = regexp.MustCompile(`\[([^\]]+)\](?:\[([^\]]*)\])?`)
+)
+
+// inlineReferences replaces the reference links and images of the markdown source by
+// inline ones, using the urls of their definitions. Labels are case-insensitive and
+// links whose label is not defined are kept as is, as well as code blocks and code spans.
+func inlineReferences(content string) string {
+	defs := map[string]string{}
+	fence := ""
+	for _, line := range strings.Split(content, "\n") {
+		if f := fenceMarker(line); f != "" {
+			if fence == "" {
+				fence = f
+			} else if strings.HasPrefix(f, fence) {
+				fence = ""
+			}
+			continue
+		}
+		if match := linkDefinitionRe.FindStringSubmatch(line); fence == "" && match != nil {
+			if label := referenceLabel(match[1]); defs[label] == "" {
+				defs[label] = match[2]
+			}
+		}
+	}
+	if len(defs) == 0 {
+		return content
+	}
+
+	return replaceInline(content, func(text string) string {
+		if linkDefinitionRe.MatchString(text) {
+			return text
+		}
+		var b strings.Builder
+		last := 0
+		for _, loc := range referenceLinkRe.FindAllStringSubmatchIndex(text, -1) {
+			// Inline links and definitions are not references
+			if loc[1] < len(text) && (text[loc[1]] == '(' || text[loc[1]] == ':') {
+				continue
+			}
+			linkText := text[loc[2]:loc[3]]
+			label := linkText
+			if loc[4] >= 0 && loc[5] > loc[4] {
+				label = text[loc[4]:loc[5]]
+			}
+			url, ok := defs[referenceLabel(label)]
+			if !ok {
+				continue
+			}
+			b.WriteString(text[last:loc[0]])
+			b.WriteString("[" + linkText + "](" + url + ")")
+			last = loc[1]
+		}
+		b.WriteString(text[last:])
+		return b.String()
+	})
+}
+
+// referenceLabel normalizes a link label: case-insensitive, with collapsed whitespace.
+func referenceLabel(label string) string {
+	return strings.ToLower(strings.Join(strings.Fields(label), " "))
+}
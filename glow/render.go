@@ -0,0 +1,330 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/glamour/ansi"
+	"github.com/charmbracelet/glamour/styles"
+	"github.com/charmbracelet/lipgloss"
+	xansi "github.com/charmbracelet/x/ansi"
+	"github.com/muesli/termenv"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/util"
+)
+
+const (
+	defaultWidth = 80
+	// priority of the ansi renderer, same as the one used by glamour
+	ansiPriority = 1000
+)
+
+// colorProfiles maps the supported color profile names to the termenv profiles.
+var colorProfiles = map[string]termenv.Profile{
+	"truecolor": termenv.TrueColor,
+	"256":       termenv.ANSI256,
+	"ansi":      termenv.ANSI,
+	"none":      termenv.Ascii,
+}
+
+// Hex color, '#rrggbb'
+var hexColorRe = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// SGR sequence, setting colors and text attributes
+var sgrRe = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// Trailing spaces of a rendered line, with the ANSI sequences styling them
+var trailingSpacesRe = regexp.MustCompile(`(?:\x1b\[[0-9;]*m|[ \t])+$`)
+
+// renderCache contains the rendered outputs, keyed by a hash of the input and the options.
+//
+// The cache only lives as long as the module process: it avoids rendering the same
+// document again in a single call (or in a series of calls handled by the same
+// runtime), while repeated calls across sessions rely on the Dagger cache.
+var renderCache sync.Map
+
+// render converts the markdown input to a string to be displayed on a terminal.
+func (m *Glow) render(str string) (string, error) {
+	key := m.cacheKey(str)
+	if out, ok := renderCache.Load(key); ok {
+		return out.(string), nil
+	}
+
+	src, ansiBlocks := str, []string(nil)
+	if m.AllowAnsi {
+		src, ansiBlocks = extractAnsiBlocks(src)
+	}
+	src = m.preprocess(src)
+	if len(m.Bullets) > 0 {
+		// Only for the terminal, HTML lists having their own bullets
+		src = listBullets(src, m.Bullets)
+	}
+	var urls []string
+	if m.Hyperlinks {
+		src, urls = hyperlinks(src)
+	}
+
+	var buf bytes.Buffer
+	if err := m.markdown().Convert([]byte(src), &buf); err != nil {
+		return "", err
+	}
+	out := buf.String()
+	if len(ansiBlocks) > 0 {
+		out = restoreAnsiBlocks(out, ansiBlocks, m.MarginLeft)
+	}
+	if detailsMarkerRe.MatchString(out) {
+		out = indentDetails(out)
+	}
+	if len(urls) > 0 {
+		out = restoreHyperlinks(out, urls)
+	}
+	if m.ColorProfile == "none" {
+		// glamour still emits text attributes like bold without colors, remove them
+		// while keeping the hyperlinks
+		out = sgrRe.ReplaceAllString(out, "")
+	}
+	if m.Trim {
+		out = trim(out)
+	}
+	renderCache.Store(key, out)
+	return out, nil
+}
+
+// preprocess applies the enabled transformations to the markdown source before parsing it
+// to render it in a terminal.
+func (m *Glow) preprocess(str string) string {
+	str = m.preprocessSource(str)
+	str = details(str, m.ExpandDetails)
+	if m.Footnotes && m.Flavor != "commonmark" {
+		str = footnotes(str)
+	}
+	if m.Math {
+		str = mathCode(str)
+	}
+	if m.NarrowTables && m.Flavor != "commonmark" {
+		// Tables are laid out on the whole width, then shifted by the left margin
+		padding := 0
+		if p := m.styles().Table.Margin; p != nil {
+			padding = int(*p)
+		}
+		str = narrowTables(str, defaultWidth-m.MarginLeft-m.MarginRight, padding)
+	}
+	if len(m.LexerAliases) > 0 {
+		// The aliases are validated when creating the module
+		if aliases, err := parseLexerAliases(m.LexerAliases); err == nil {
+			str = lexerAliases(str, aliases)
+		}
+	}
+	if m.ImagePlaceholder != "" || m.Hyperlinks {
+		// Reference links and images are only converted in their inline form
+		str = inlineReferences(str)
+	}
+	if m.ImagePlaceholder != "" {
+		// The template is validated when creating the module
+		if tmpl, err := parseImagePlaceholder(m.ImagePlaceholder); err == nil {
+			str = imagePlaceholders(str, tmpl)
+		}
+	}
+	return str
+}
+
+// preprocessSource applies the enabled transformations of the markdown source itself,
+// independent of the output format. The other ones only target the terminal and
+// are not applied to the HTML output.
+func (m *Glow) preprocessSource(str string) string {
+	if m.PandocTitleBlock {
+		str = pandocTitleBlockToMarkdown(str)
+	}
+	if m.StripComments {
+		str = stripComments(str)
+	}
+	if m.Demote > 0 {
+		str = demoteHeadings(str, m.Demote)
+	}
+	return str
+}
+
+// cacheKey returns a hash of the markdown input and of all the rendering options.
+func (m *Glow) cacheKey(str string) string {
+	h := sha256.New()
+	// All the options are part of the Glow struct, they can't fail to be marshaled.
+	opts, _ := json.Marshal(m)
+	h.Write(opts)
+	h.Write([]byte{0})
+	h.Write([]byte(str))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// extensions returns the goldmark extensions of the configured flavor.
+func (m *Glow) extensions() []goldmark.Extender {
+	if m.Flavor == "commonmark" {
+		return nil
+	}
+	return []goldmark.Extender{extension.GFM, extension.DefinitionList}
+}
+
+// markdown returns the goldmark pipeline parsing the configured flavor and rendering to ANSI.
+func (m *Glow) markdown() goldmark.Markdown {
+	md := goldmark.New(
+		goldmark.WithExtensions(m.extensions()...),
+		goldmark.WithParserOptions(
+			parser.WithAutoHeadingID(),
+		),
+	)
+	// Set the renderer once extensions are registered, so only the ANSI renderer is used,
+	// not the HTML ones registered by the extensions.
+	md.SetRenderer(
+		renderer.NewRenderer(
+			renderer.WithNodeRenderers(
+				util.Prioritized(ansi.NewRenderer(m.options()), ansiPriority),
+			),
+		),
+	)
+	return md
+}
+
+// options returns the ANSI rendering options.
+func (m *Glow) options() ansi.Options {
+	return ansi.Options{
+		WordWrap:     defaultWidth - m.MarginRight,
+		ColorProfile: colorProfiles[m.ColorProfile],
+		Styles:       m.styles(),
+	}
+}
+
+// styles returns the style configuration, based on the dark style and updated
+// with the configured options.
+// Pointers of the base style must not be modified as they are shared, replace them instead.
+func (m *Glow) styles() ansi.StyleConfig {
+	s := styles.DarkStyleConfig
+	s.Document.Margin = uintPtr(uint(m.MarginLeft))
+	if m.MarginLeft == 0 {
+		// The highlighted title is padded with a space, leaving no margin means no leading space
+		s.H1.Prefix = ""
+	}
+	prefix := m.BlockquotePrefix
+	if m.BlockquoteColor != "" {
+		prefix = termenv.String(prefix).Foreground(colorProfiles[m.ColorProfile].Color(m.BlockquoteColor)).String()
+	}
+	s.BlockQuote.IndentToken = stringPtr(prefix + " ")
+	if m.CodeColor != "" {
+		s.Code.Color = stringPtr(m.CodeColor)
+	}
+	if m.CodeBackground != "" {
+		s.Code.BackgroundColor = stringPtr(m.CodeBackground)
+	}
+	// Highlight the lines of diff code blocks with a background, the colors of the
+	// added and removed lines being hard to tell apart from the other tokens otherwise
+	chroma := *s.CodeBlock.Chroma
+	chroma.GenericInserted = ansi.StylePrimitive{Color: stringPtr("#5FFF87"), BackgroundColor: stringPtr("#005F00")}
+	chroma.GenericDeleted = ansi.StylePrimitive{Color: stringPtr("#FF5F5F"), BackgroundColor: stringPtr("#5F0000")}
+	chroma.GenericSubheading = ansi.StylePrimitive{Color: stringPtr("#5FAFFF")}
+	s.CodeBlock.Chroma = &chroma
+	if len(m.Bullets) > 0 {
+		// The bullets are part of the items contents
+		s.Item.BlockPrefix = ""
+	}
+	s.Enumeration.BlockPrefix = m.EnumerationSuffix + " "
+	if m.HeadingStyle == "hashes" {
+		for i, h := range []*ansi.StyleBlock{&s.H1, &s.H2, &s.H3, &s.H4, &s.H5, &s.H6} {
+			*h = ansi.StyleBlock{StylePrimitive: ansi.StylePrimitive{Prefix: strings.Repeat("#", i+1) + " "}}
+		}
+	}
+	if m.Compact {
+		s.Document.BlockPrefix = ""
+		s.Document.BlockSuffix = ""
+		s.Heading.BlockSuffix = ""
+	}
+	return s
+}
+
+// validColor returns whether the color is an ANSI color number or a '#rrggbb' hex color.
+func validColor(c string) bool {
+	if strings.HasPrefix(c, "#") {
+		return hexColorRe.MatchString(c)
+	}
+	n, err := strconv.Atoi(c)
+	return err == nil && n >= 0 && n <= 255
+}
+
+func uintPtr(u uint) *uint {
+	return &u
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+// trim removes the trailing whitespace of the rendered lines and the trailing blank lines.
+// Blank lines are emptied, and styles are reset at the end of the lines whose
+// trailing ANSI sequences are removed.
+func trim(content string) string {
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(xansi.Strip(line)) == "" {
+			lines[i] = ""
+			continue
+		}
+		lines[i] = trailingSpacesRe.ReplaceAllStringFunc(line, func(s string) string {
+			if strings.Contains(s, "\x1b[") {
+				return "\x1b[0m"
+			}
+			return ""
+		})
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// width returns the number of columns of the longest line, ignoring ANSI sequences
+// and trailing spaces.
+func width(content string) int {
+	w := 0
+	for _, line := range strings.Split(content, "\n") {
+		w = max(w, lineWidth(line))
+	}
+	return w
+}
+
+// lineWidth returns the number of columns of a line, ignoring ANSI sequences
+// and trailing spaces.
+func lineWidth(line string) int {
+	return lipgloss.Width(strings.TrimRight(xansi.Strip(line), " "))
+}
+
+// box frames the content with a rounded border, the title being part of the top edge.
+// Widths are computed on the visible characters, ignoring ANSI sequences and
+// taking wide characters into account.
+func box(content, title string) string {
+	lines := strings.Split(strings.TrimRight(content, "\n"), "\n")
+	width := lipgloss.Width(title) + 2
+	for _, line := range lines {
+		width = max(width, lipgloss.Width(line))
+	}
+
+	var b strings.Builder
+	top := "─ " + title + " "
+	b.WriteString("╭" + top + strings.Repeat("─", width+2-lipgloss.Width(top)) + "╮\n")
+	for _, line := range lines {
+		b.WriteString("│ " + line + strings.Repeat(" ", width-lipgloss.Width(line)) + " │\n")
+	}
+	b.WriteString("╰" + strings.Repeat("─", width+2) + "╯\n")
+	return b.String()
+}
+
+// separator returns a full width line including the given name.
+func separator(name string) string {
+	line := "── " + name + " "
+	return line + strings.Repeat("─", max(defaultWidth-lipgloss.Width(line), 0)) + "\n"
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	xansi "github.com/charmbracelet/x/ansi"
+)
+
+// testGlow returns the default options of the tests, rendering GFM without
+// colors, as the default values of New.
+func testGlow() *Glow {
+	return &Glow{Flavor: "gfm", ColorProfile: "none", Trim: true, HeadingStyle: "styled", EnumerationSuffix: "."}
+}
+
+func TestRenderHeadingStyles(t *testing.T) {
+	in := "# Title\n\n## Sub\n\n###### Six\n"
+	tests := []struct {
+		name    string
+		style   string
+		want    string
+		colors  []string
+		without []string
+	}{
+		{
+			name:  "styled",
+			style: "styled",
+			want:  "\n   Title\n\n  ## Sub\n\n  ###### Six\n",
+			// Highlighted title, and a color by level
+			colors: []string{"\x1b[38;5;228;48;5;63;1mTitle", "\x1b[38;5;39;1m## ", "\x1b[38;5;35m###### "},
+		},
+		{
+			name:   "hashes",
+			style:  "hashes",
+			want:   "\n  # Title\n\n  ## Sub\n\n  ###### Six\n",
+			colors: []string{"\x1b[38;5;39;1m# ", "\x1b[38;5;39;1m## ", "\x1b[38;5;39;1m###### "},
+			// No highlighted title
+			without: []string{"48;5;63"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := testGlow()
+			m.ColorProfile, m.MarginLeft, m.HeadingStyle = "256", 2, tt.style
+			out, err := m.render(in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := xansi.Strip(out); got != tt.want {
+				t.Errorf("render() = %q, want %q", got, tt.want)
+			}
+			for _, c := range tt.colors {
+				if !strings.Contains(out, c) {
+					t.Errorf("render() = %q, should contain %q", out, c)
+				}
+			}
+			for _, c := range tt.without {
+				if strings.Contains(out, c) {
+					t.Errorf("render() = %q, should not contain %q", out, c)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderZeroMargin(t *testing.T) {
+	in := "# Title\n\n## Sub\n\ntext\n\n- item\n"
+	for _, style := range []string{"styled", "hashes"} {
+		t.Run(style, func(t *testing.T) {
+			m := testGlow()
+			m.ColorProfile, m.HeadingStyle = "256", style
+			out, err := m.render(in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, line := range strings.Split(xansi.Strip(out), "\n") {
+				if strings.HasPrefix(line, " ") {
+					t.Errorf("render() line %q should not start with a space", line)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderFlavors(t *testing.T) {
+	in := "| a | b |\n|---|---|\n| 1 | 2 |\n"
+	tests := []struct {
+		flavor string
+		want   []string
+	}{
+		// Rendered as a table
+		{flavor: "gfm", want: []string{"\na ", "│b\n", "┼", "\n1 ", "│2\n"}},
+		// Rendered as a paragraph
+		{flavor: "commonmark", want: []string{"\n| a | b | |---|---| | 1 | 2 |\n"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.flavor, func(t *testing.T) {
+			m := testGlow()
+			m.Flavor = tt.flavor
+			out, err := m.render(in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(out, want) {
+					t.Errorf("render() = %q, should contain %q", out, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderNestedBlockquotes(t *testing.T) {
+	in := "> one\n>\n> > two\n> >\n> > > three\n"
+	tests := []struct {
+		name   string
+		prefix string
+		color  string
+		want   string
+	}{
+		{
+			name:   "prefix",
+			prefix: "┃",
+			want:   "\n\n┃ one\n┃\n┃ ┃ two\n┃ ┃\n┃ ┃ ┃ three\n",
+		},
+		{
+			name:   "colored prefix",
+			prefix: ">",
+			color:  "1",
+			want:   "\n\n> one\n>\n> > two\n> >\n> > > three\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := testGlow()
+			m.ColorProfile, m.BlockquotePrefix, m.BlockquoteColor = "ansi", tt.prefix, tt.color
+			out, err := m.render(in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := xansi.Strip(out); got != tt.want {
+				t.Errorf("render() = %q, want %q", got, tt.want)
+			}
+			if tt.color == "" {
+				return
+			}
+			// Each level's prefix is colored
+			for _, line := range strings.Split(out, "\n") {
+				stripped := xansi.Strip(line)
+				if n := strings.Count(line, "\x1b[31m"+tt.prefix); n != strings.Count(stripped, tt.prefix) {
+					t.Errorf("render() line %q has %d colored prefixes, want %d", line, n, strings.Count(stripped, tt.prefix))
+				}
+			}
+		})
+	}
+}
+
+func TestRenderInlineCode(t *testing.T) {
+	tests := []struct {
+		name       string
+		color      string
+		background string
+		want       string
+	}{
+		{name: "default", want: "\x1b[38;5;203;48;5;236m code \x1b[0m"},
+		{name: "color", color: "#ff0000", want: "\x1b[38;2;255;0;0;48;5;236m code \x1b[0m"},
+		{name: "background", background: "#00ff00", want: "\x1b[38;5;203;48;2;0;255;0m code \x1b[0m"},
+		{name: "both", color: "1", background: "#00ff00", want: "\x1b[31;48;2;0;255;0m code \x1b[0m"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := testGlow()
+			m.ColorProfile, m.CodeColor, m.CodeBackground = "truecolor", tt.color, tt.background
+			out, err := m.render("use `code` here\n\n```\nblock\n```\n")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(out, tt.want) {
+				t.Errorf("render() = %q, should contain %q", out, tt.want)
+			}
+			// Code blocks keep their style
+			if !strings.Contains(out, "\x1b[38;5;251mblock") {
+				t.Errorf("render() = %q, should keep the code block style", out)
+			}
+		})
+	}
+}
+
+func TestRenderDiff(t *testing.T) {
+	m := testGlow()
+	m.ColorProfile = "256"
+	out, err := m.render("```diff\n@@ -1,2 +1,2 @@\n context\n+added\n-removed\n```\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		// Added lines in green on a dark green background
+		"\x1b[38;5;84m\x1b[48;5;22m+added",
+		// Removed lines in red on a dark red background
+		"\x1b[38;5;203m\x1b[48;5;52m-removed",
+		// Hunk headers in blue
+		"\x1b[38;5;75m@@ -1,2 +1,2 @@",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("render() = %q, should contain %q", out, want)
+		}
+	}
+	if strings.Contains(out, "48;5;22m context") || strings.Contains(out, "48;5;52m context") {
+		t.Errorf("render() = %q, should not highlight the context lines", out)
+	}
+}
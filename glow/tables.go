@@ -0,0 +1,95 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Markup not taken into account in the width of a table cell
+var cellMarkup = strings.NewReplacer("**", "", "__", "", "`", "", `\|`, "|")
+
+// narrowTables replaces the tables of the markdown source wider than the width by lists,
+// each row becoming an item with the value of its first column and nested
+// 'header: value' items for the other ones. Padding is the number of columns
+// added on each side of the cells.
+func narrowTables(content string, width, padding int) string {
+	lines := strings.Split(content, "\n")
+	var out []string
+	fence := ""
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if f := fenceMarker(line); f != "" {
+			if fence == "" {
+				fence = f
+			} else if strings.HasPrefix(f, fence) {
+				fence = ""
+			}
+		}
+		if fence != "" || i+1 >= len(lines) || !strings.Contains(line, "|") || !tableDelimiterRe.MatchString(lines[i+1]) {
+			out = append(out, line)
+			continue
+		}
+
+		end := i + 2
+		for end < len(lines) && strings.TrimSpace(lines[end]) != "" && strings.Contains(lines[end], "|") {
+			end++
+		}
+		header := tableCells(line)
+		var rows [][]string
+		for _, row := range lines[i+2 : end] {
+			rows = append(rows, tableCells(row))
+		}
+		if tableWidth(header, rows, padding) <= width {
+			out = append(out, lines[i:end]...)
+		} else {
+			out = append(out, tableList(header, rows)...)
+		}
+		i = end - 1
+	}
+	return strings.Join(out, "\n")
+}
+
+// tableWidth estimates the rendered width of a table: the widest cell of each
+// column with its padding, and the border between the columns. glamour doesn't
+// draw the outer borders.
+func tableWidth(header []string, rows [][]string, padding int) int {
+	widths := make([]int, len(header))
+	for _, row := range append([][]string{header}, rows...) {
+		for j, cell := range row {
+			if j < len(widths) {
+				widths[j] = max(widths[j], lipgloss.Width(cellMarkup.Replace(strings.TrimSpace(cell))))
+			}
+		}
+	}
+	w := len(widths) - 1
+	for _, cw := range widths {
+		w += cw + 2*padding
+	}
+	return w
+}
+
+// tableList returns the markdown list of the rows of a table. Empty cells are skipped.
+func tableList(header []string, rows [][]string) []string {
+	out := []string{""}
+	for _, row := range rows {
+		for j, name := range header {
+			value := ""
+			if j < len(row) {
+				value = strings.TrimSpace(row[j])
+			}
+			if j > 0 && value == "" {
+				continue
+			}
+			item := "- "
+			if j > 0 {
+				item = "  - "
+			}
+			if name = strings.TrimSpace(name); name != "" {
+				item += "**" + name + "**: "
+			}
+			out = append(out, item+value)
+		}
+	}
+	return append(out, "")
+}
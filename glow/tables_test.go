@@ -0,0 +1,88 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTableWidth(t *testing.T) {
+	header := []string{"Name", "Description"}
+	rows := [][]string{{"`glow`", "Render **markdown**"}}
+	tests := []struct {
+		name    string
+		padding int
+		want    int
+	}{
+		{name: "borders only", padding: 0, want: 4 + 1 + 15},
+		{name: "padded cells", padding: 1, want: 4 + 1 + 15 + 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tableWidth(header, rows, tt.padding); got != tt.want {
+				t.Errorf("tableWidth() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNarrowTables(t *testing.T) {
+	wide := "| Name | Description |\n|---|---|\n| a | " + strings.Repeat("x", 80) + " |\n| b |  |"
+	narrow := "| Name | Description |\n|---|---|\n| a | short |"
+	tests := []struct {
+		name  string
+		in    string
+		width int
+		want  string
+	}{
+		{
+			name:  "narrow table kept",
+			in:    narrow,
+			width: 80,
+			want:  narrow,
+		},
+		{
+			name:  "wide table as a list",
+			in:    "text\n" + wide + "\nafter",
+			width: 80,
+			want:  "text\n\n- **Name**: a\n  - **Description**: " + strings.Repeat("x", 80) + "\n- **Name**: b\n\nafter",
+		},
+		{
+			name:  "margins counted",
+			in:    "| a | b |\n|---|---|\n| c | " + strings.Repeat("x", 77) + " |",
+			width: 78,
+			want:  "\n- **a**: c\n  - **b**: " + strings.Repeat("x", 77) + "\n",
+		},
+		{
+			name:  "code blocks",
+			in:    "```\n" + wide + "\n```",
+			width: 80,
+			want:  "```\n" + wide + "\n```",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := narrowTables(tt.in, tt.width, 0); got != tt.want {
+				t.Errorf("narrowTables() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderWideTable(t *testing.T) {
+	m := testGlow()
+	m.MarginLeft, m.NarrowTables = 2, true
+	// 4 + 1 + 75 columns fit in 80 columns, not with the left margin
+	desc := strings.TrimSpace(strings.Repeat("words ", 12)) + " end"
+	out, err := m.render("| Name | Description |\n|---|---|\n| glow | " + desc + " |\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "Name: glow") || strings.Contains(out, "│") {
+		t.Errorf("render() = %q, should render the table as a list", out)
+	}
+	for _, line := range strings.Split(out, "\n") {
+		if w := lineWidth(line); w > defaultWidth {
+			t.Errorf("render() line %q is %d columns wide, want at most %d", line, w, defaultWidth)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var tableDelimiterRe = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+
+// validate checks the structure of the markdown content and returns the list of issues:
+// tables with inconsistent column counts, unclosed code fences and headings skipping levels.
+// Only ATX headings ('# title') are considered.
+func validate(content string) []string {
+	var (
+		issues       []string
+		fence        string
+		fenceLine    int
+		headingLevel int
+		tableColumns int
+	)
+
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		n := i + 1
+
+		if f := fenceMarker(line); f != "" {
+			if fence == "" {
+				fence, fenceLine = f, n
+				tableColumns = 0
+				continue
+			} else if strings.HasPrefix(f, fence) {
+				fence = ""
+				continue
+			}
+		}
+		if fence != "" {
+			continue
+		}
+
+		if tableColumns > 0 {
+			if strings.TrimSpace(line) == "" || !strings.Contains(line, "|") {
+				tableColumns = 0
+			} else if c := len(tableCells(line)); c != tableColumns {
+				issues = append(issues, fmt.Sprintf("line %d: table row has %d columns, header has %d", n, c, tableColumns))
+			}
+			continue
+		}
+
+		if i+1 < len(lines) && strings.Contains(line, "|") && tableDelimiterRe.MatchString(lines[i+1]) {
+			tableColumns = len(tableCells(line))
+			if c := len(tableCells(lines[i+1])); c != tableColumns {
+				issues = append(issues, fmt.Sprintf("line %d: table delimiter row has %d columns, header has %d", n+1, c, tableColumns))
+			}
+			continue
+		}
+
+		if match := headingRe.FindStringSubmatch(line); match != nil {
+			level := len(match[1])
+			if level > headingLevel+1 {
+				issues = append(issues, fmt.Sprintf("line %d: heading level %d skips level %d", n, level, headingLevel+1))
+			}
+			headingLevel = level
+		}
+	}
+
+	if fence != "" {
+		issues = append(issues, fmt.Sprintf("line %d: code fence %s is never closed", fenceLine, fence))
+	}
+	return issues
+}
+
+// tableCells splits a table row into its cells, ignoring escaped pipes.
+func tableCells(row string) []string {
+	row = strings.TrimSpace(row)
+	row = strings.TrimPrefix(row, "|")
+	if strings.HasSuffix(row, "|") && !strings.HasSuffix(row, `\|`) {
+		row = strings.TrimSuffix(row, "|")
+	}
+
+	var (
+		cells []string
+		cell  strings.Builder
+	)
+	for i := 0; i < len(row); i++ {
+		switch {
+		case row[i] == '\\' && i+1 < len(row) && row[i+1] == '|':
+			cell.WriteString(`\|`)
+			i++
+		case row[i] == '|':
+			cells = append(cells, cell.String())
+			cell.Reset()
+		default:
+			cell.WriteByte(row[i])
+		}
+	}
+	return append(cells, cell.String())
+}
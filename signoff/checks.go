@@ -0,0 +1,451 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// Query of the statuses and check runs of a commit, by pages of 100 contexts
+const commitStateQuery = `query($owner: String!, $repo: String!, $sha: GitObjectID!, $cursor: String) {
+  repository(owner: $owner, name: $repo) {
+    object(oid: $sha) {
+      ... on Commit {
+        statusCheckRollup {
+          state
+          contexts(first: 100, after: $cursor) {
+            pageInfo { hasNextPage endCursor }
+            nodes {
+              __typename
+              ... on StatusContext { context state description }
+              ... on CheckRun { name status conclusion }
+            }
+          }
+        }
+      }
+    }
+  }
+}`
+
+// commitState is the combined state of the statuses and check runs of a commit.
+type commitState struct {
+	// Combined state: SUCCESS, PENDING, FAILURE, ERROR or EXPECTED
+	State    string
+	Contexts []commitContext
+}
+
+// commitContext is a commit status or a check run.
+type commitContext struct {
+	Name        string
+	State       string
+	Description string
+}
+
+// Get the state of the configured check on a commit: success, pending,
+// failure or error. The result is empty if there is no such check.
+func (m *Signoff) Status(
+	ctx context.Context,
+	// Commit SHA, default to the current commit
+	// +optional
+	sha string,
+) (string, error) {
+	if sha == "" {
+		var err error
+		if sha, err = m.Sha(ctx); err != nil {
+			return "", err
+		}
+	}
+
+	state, err := m.commitState(ctx, sha)
+	if err != nil {
+		return "", err
+	}
+	for _, c := range state.Contexts {
+		if c.Name == m.CheckName {
+			return strings.ToLower(c.State), nil
+		}
+	}
+	return "", nil
+}
+
+// List all the statuses and check runs of a commit, with their state and description.
+//
+// This shows whether the external CI systems posting statuses have passed
+// before signing off.
+func (m *Signoff) ExternalStatuses(
+	ctx context.Context,
+	// Commit SHA, default to the current commit
+	// +optional
+	sha string,
+) (string, error) {
+	if sha == "" {
+		var err error
+		if sha, err = m.Sha(ctx); err != nil {
+			return "", err
+		}
+	}
+
+	state, err := m.commitState(ctx, sha)
+	if err != nil {
+		return "", err
+	}
+	if len(state.Contexts) == 0 {
+		return "No status on " + sha, nil
+	}
+
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "CONTEXT\tSTATE\tDESCRIPTION")
+	for _, c := range state.Contexts {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", c.Name, strings.ToLower(c.State), c.Description)
+	}
+	w.Flush()
+	return strings.TrimRight(b.String(), "\n"), nil
+}
+
+// Get the state of several checks on a commit, as a JSON object mapping each
+// context to its state: success, pending, failure or error. The state is empty
+// for the contexts without any status or check run.
+//
+// All the states are fetched at once, whatever the number of contexts.
+func (m *Signoff) StatusAll(
+	ctx context.Context,
+	// Names of the status contexts or check runs
+	contexts []string,
+	// Commit SHA, default to the current commit
+	// +optional
+	sha string,
+) (string, error) {
+	if sha == "" {
+		var err error
+		if sha, err = m.Sha(ctx); err != nil {
+			return "", err
+		}
+	}
+
+	state, err := m.commitState(ctx, sha)
+	if err != nil {
+		return "", err
+	}
+	states := make(map[string]string, len(contexts))
+	for _, name := range contexts {
+		states[name] = ""
+	}
+	for _, c := range state.Contexts {
+		if s, ok := states[c.Name]; ok && s == "" {
+			states[c.Name] = strings.ToLower(c.State)
+		}
+	}
+
+	out, err := json.Marshal(states)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// Check if the current commit is already signed off, the configured check being successful.
+func (m *Signoff) IsSignedOff(ctx context.Context) (bool, error) {
+	status, err := m.Status(ctx, "")
+	if err != nil {
+		return false, err
+	}
+	return status == "success", nil
+}
+
+// Get the URL of a shields.io badge showing the state of the configured check on a commit.
+//
+// The URL is a static badge, 'https://img.shields.io/badge/<check>-<state>-<color>':
+// green when signed off, yellow when pending, red on failure or error and grey
+// when there is no signoff yet.
+func (m *Signoff) BadgeURL(
+	ctx context.Context,
+	// Commit SHA, default to the current commit
+	// +optional
+	sha string,
+) (string, error) {
+	status, err := m.Status(ctx, sha)
+	if err != nil {
+		return "", err
+	}
+
+	message, color := "not signed off", "lightgrey"
+	switch status {
+	case "success":
+		message, color = "signed off", "green"
+	case "pending":
+		message, color = "pending", "yellow"
+	case "failure", "error":
+		message, color = status, "red"
+	}
+	return "https://img.shields.io/badge/" + badgeText(m.CheckName) + "-" + badgeText(message) + "-" + color, nil
+}
+
+// badgeText escapes a text of a static shields.io badge: dashes and underscores
+// are doubled, spaces replaced by underscores.
+func badgeText(text string) string {
+	text = strings.NewReplacer("-", "--", "_", "__", " ", "_").Replace(text)
+	return url.PathEscape(text)
+}
+
+// commitState fetches the statuses and check runs of a commit using the GraphQL API.
+// If it fails, the REST API is used instead, only returning the commit statuses.
+func (m *Signoff) commitState(ctx context.Context, sha string) (*commitState, error) {
+	state, err := m.commitStateGraphQL(ctx, sha)
+	if err == nil {
+		return state, nil
+	}
+	m.debug("GraphQL commit state query failed, using REST API: %v", err)
+	return m.commitStateREST(ctx, sha)
+}
+
+func (m *Signoff) commitStateGraphQL(ctx context.Context, sha string) (*commitState, error) {
+	owner, repo := "{owner}", "{repo}"
+	if m.Owner != "" {
+		owner, repo = m.Owner, m.Repo
+	}
+
+	var state *commitState
+	cursor := ""
+	for {
+		args := []string{
+			"api", "graphql",
+			"-f", "query=" + commitStateQuery,
+			"-F", "owner=" + owner,
+			"-F", "repo=" + repo,
+			"-f", "sha=" + sha,
+		}
+		if cursor != "" {
+			args = append(args, "-f", "cursor="+cursor)
+		}
+		out, err := m.WithGhExec(args).Out(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("%w\n%s", err, out)
+		}
+		out, err = m.Stdout(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		var resp struct {
+			Data struct {
+				Repository struct {
+					Object struct {
+						StatusCheckRollup *struct {
+							State    string `json:"state"`
+							Contexts struct {
+								PageInfo struct {
+									HasNextPage bool   `json:"hasNextPage"`
+									EndCursor   string `json:"endCursor"`
+								} `json:"pageInfo"`
+								Nodes []struct {
+									Typename    string `json:"__typename"`
+									Context     string `json:"context"`
+									State       string `json:"state"`
+									Description string `json:"description"`
+									Name        string `json:"name"`
+									Status      string `json:"status"`
+									Conclusion  string `json:"conclusion"`
+								} `json:"nodes"`
+							} `json:"contexts"`
+						} `json:"statusCheckRollup"`
+					} `json:"object"`
+				} `json:"repository"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal([]byte(out), &resp); err != nil {
+			return nil, fmt.Errorf("could not parse commit state: %w", err)
+		}
+
+		rollup := resp.Data.Repository.Object.StatusCheckRollup
+		if rollup == nil {
+			// No status nor check run on the commit yet
+			return &commitState{State: "PENDING"}, nil
+		}
+
+		if state == nil {
+			state = &commitState{State: rollup.State}
+		}
+		for _, node := range rollup.Contexts.Nodes {
+			if node.Typename == "CheckRun" {
+				s := node.Conclusion
+				if node.Status != "COMPLETED" {
+					s = "PENDING"
+				}
+				state.Contexts = append(state.Contexts, commitContext{Name: node.Name, State: s})
+				continue
+			}
+			state.Contexts = append(state.Contexts, commitContext{Name: node.Context, State: node.State, Description: node.Description})
+		}
+
+		if !rollup.Contexts.PageInfo.HasNextPage {
+			return state, nil
+		}
+		cursor = rollup.Contexts.PageInfo.EndCursor
+	}
+}
+
+func (m *Signoff) commitStateREST(ctx context.Context, sha string) (*commitState, error) {
+	out, err := m.WithGhExec([]string{
+		"api",
+		"repos/:owner/:repo/commits/" + sha + "/status",
+	}).Out(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not get the status of %s: %w\n%s", sha, err, out)
+	}
+	out, err = m.Stdout(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		State    string `json:"state"`
+		Statuses []struct {
+			Context     string `json:"context"`
+			State       string `json:"state"`
+			Description string `json:"description"`
+		} `json:"statuses"`
+	}
+	if err := json.Unmarshal([]byte(out), &resp); err != nil {
+		return nil, fmt.Errorf("could not parse commit status: %w", err)
+	}
+
+	state := &commitState{State: strings.ToUpper(resp.State)}
+	for _, s := range resp.Statuses {
+		state.Contexts = append(state.Contexts, commitContext{Name: s.Context, State: strings.ToUpper(s.State), Description: s.Description})
+	}
+	return state, nil
+}
+
+// List the signoff statuses of the current commit, oldest first, with their state,
+// creator and date. This gives the signoff audit trail of the commit.
+func (m *Signoff) History(
+	ctx context.Context,
+	// Maximum number of statuses to list, the most recent ones are kept
+	// +optional
+	// +default=10
+	limit int,
+) (string, error) {
+	sha, err := m.Sha(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	// Statuses are returned in reverse chronological order, one per line
+	out, err := m.WithGhExec([]string{
+		"api", "--paginate",
+		"repos/:owner/:repo/commits/" + sha + "/statuses",
+		"--jq", fmt.Sprintf(".[] | select(.context == %q) | [.created_at, .state, .creator.login, .description] | @tsv", m.CheckName),
+	}).Out(ctx)
+	if err != nil {
+		return "", fmt.Errorf("could not get the statuses of %s: %w\n%s", sha, err, out)
+	}
+	out, err = m.Stdout(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var lines []string
+	for _, line := range strings.Split(out, "\n") {
+		if line == "" {
+			continue
+		}
+		if limit > 0 && len(lines) == limit {
+			break
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		return fmt.Sprintf("No %s status on %s", m.CheckName, sha), nil
+	}
+	slices.Reverse(lines)
+	return strings.Join(lines, "\n"), nil
+}
+
+// Create a successful check run on the current commit.
+//
+// Check runs support a title, a summary and annotations, giving a richer
+// experience than the commit status posted by create.
+// The GitHub API only allows GitHub Apps to create check runs: the token must be
+// a GitHub App installation token, with the checks:write permission.
+func (m *Signoff) CreateCheckRun(
+	ctx context.Context,
+	// Title of the check run
+	title string,
+	// Summary of the check run, supports markdown
+	summary string,
+	// Annotations, formatted as 'path:line:message'
+	// +optional
+	annotations []string,
+) error {
+	sha, err := m.Sha(ctx)
+	if err != nil {
+		return err
+	}
+
+	type annotation struct {
+		Path            string `json:"path"`
+		StartLine       int    `json:"start_line"`
+		EndLine         int    `json:"end_line"`
+		AnnotationLevel string `json:"annotation_level"`
+		Message         string `json:"message"`
+	}
+	output := struct {
+		Title       string       `json:"title"`
+		Summary     string       `json:"summary"`
+		Annotations []annotation `json:"annotations,omitempty"`
+	}{
+		Title:   title,
+		Summary: summary,
+	}
+	for _, a := range annotations {
+		parts := strings.SplitN(a, ":", 3)
+		if len(parts) != 3 {
+			return fmt.Errorf("invalid annotation %q, expected 'path:line:message'", a)
+		}
+		line, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return fmt.Errorf("invalid line in annotation %q: %w", a, err)
+		}
+		output.Annotations = append(output.Annotations, annotation{
+			Path:            parts[0],
+			StartLine:       line,
+			EndLine:         line,
+			AnnotationLevel: "warning",
+			Message:         strings.TrimSpace(parts[2]),
+		})
+	}
+
+	body, err := json.Marshal(map[string]any{
+		"name":       m.CheckName,
+		"head_sha":   sha,
+		"status":     "completed",
+		"conclusion": "success",
+		"output":     output,
+	})
+	if err != nil {
+		return err
+	}
+
+	out, err := m.withGhExecStdin([]string{
+		"api",
+		"--method", "POST",
+		"repos/:owner/:repo/check-runs",
+		"--input", "-",
+	}, string(body)).Out(ctx)
+	if err != nil {
+		if strings.Contains(out, "GitHub App") {
+			return fmt.Errorf("check runs can only be created with a GitHub App token: %w\n%s", err, out)
+		}
+		return fmt.Errorf("could not create check run on %s: %w\n%s", sha, err, out)
+	}
+
+	m.info("✓ Check run %s created on %s", m.CheckName, sha)
+
+	return nil
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Locations of the CODEOWNERS file, by order of precedence as for GitHub
+var codeownersPaths = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+
+// Comment of a CODEOWNERS line, '\#' being an escaped '#'
+var codeownersCommentRe = regexp.MustCompile(`(^|[^\\])#.*`)
+
+// codeownersRule associates the files matching a pattern to their owners.
+type codeownersRule struct {
+	Pattern *regexp.Regexp
+	// Owners: '@user', '@org/team' or email address
+	Owners []string
+}
+
+// Check the authenticated user owns the files changed by the pull request,
+// according to the CODEOWNERS file. Create runs it first when code owners are
+// required, to enforce the ownership at signoff time.
+//
+// The CODEOWNERS file is looked for in '.github/', at the root and in 'docs/'.
+// As for GitHub, the last matching pattern gives the owners of a file, and the
+// files without owner can be signed off by anyone. Team owners require the
+// 'read:org' scope to check the membership of the user.
+func (m *Signoff) RequireCodeowners(ctx context.Context) error {
+	rules, err := m.codeowners(ctx)
+	if err != nil {
+		return err
+	}
+	files, err := m.ChangedFiles(ctx, "")
+	if err != nil {
+		return err
+	}
+	user, err := m.WhoIs(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Owners of the files the user doesn't own, by file
+	var missing []string
+	for _, file := range files {
+		owners := fileOwners(rules, file)
+		if len(owners) == 0 {
+			continue
+		}
+		owner, err := m.isOwner(ctx, user, owners)
+		if err != nil {
+			return err
+		}
+		if owner {
+			continue
+		}
+		missing = append(missing, fmt.Sprintf("  %s: %s", file, strings.Join(owners, " ")))
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%s is not an owner of all the changed files, a signoff is required from:\n%s", user, strings.Join(missing, "\n"))
+	}
+
+	m.info("✓ %s owns the %d changed files", user, len(files))
+
+	return nil
+}
+
+// codeowners reads and parses the CODEOWNERS file of the sources.
+func (m *Signoff) codeowners(ctx context.Context) ([]codeownersRule, error) {
+	for _, p := range codeownersPaths {
+		files, err := m.Sources.Glob(ctx, p)
+		if err != nil {
+			return nil, fmt.Errorf("could not look for %s: %w", p, err)
+		}
+		if len(files) == 0 {
+			continue
+		}
+		content, err := m.Sources.File(p).Contents(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("could not read %s: %w", p, err)
+		}
+		m.debug("using %s", p)
+		return parseCodeowners(content)
+	}
+	return nil, fmt.Errorf("no CODEOWNERS file found in .github/, at the root or in docs/")
+}
+
+// parseCodeowners returns the rules of a CODEOWNERS file, in order.
+func parseCodeowners(content string) ([]codeownersRule, error) {
+	var rules []codeownersRule
+	for i, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(codeownersCommentRe.ReplaceAllString(line, "$1"))
+		if len(fields) == 0 {
+			continue
+		}
+		re, err := codeownersPattern(strings.ReplaceAll(fields[0], `\#`, "#"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid CODEOWNERS pattern %q on line %d: %w", fields[0], i+1, err)
+		}
+		rules = append(rules, codeownersRule{Pattern: re, Owners: fields[1:]})
+	}
+	return rules, nil
+}
+
+// codeownersPattern converts a CODEOWNERS pattern, following the gitignore rules, to a regexp
+// matching the paths of the files. A pattern matching a directory matches all its files,
+// except when ending with '/*' which only matches the files directly inside it.
+func codeownersPattern(pattern string) (*regexp.Regexp, error) {
+	p := strings.TrimSuffix(pattern, "/")
+	// A pattern with a leading or middle slash is relative to the root
+	anchored := strings.Contains(p, "/")
+	p = strings.TrimPrefix(p, "/")
+
+	var b strings.Builder
+	if anchored {
+		b.WriteString("^")
+	} else {
+		b.WriteString("^(?:.*/)?")
+	}
+	for i := 0; i < len(p); i++ {
+		switch {
+		case strings.HasPrefix(p[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(p[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case p[i] == '*':
+			b.WriteString("[^/]*")
+		case p[i] == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(p[i : i+1]))
+		}
+	}
+	if !strings.HasSuffix(pattern, "/*") {
+		b.WriteString("(?:/.*)?")
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+// fileOwners returns the owners of the file, given by the last matching rule.
+func fileOwners(rules []codeownersRule, file string) []string {
+	for _, rule := range slices.Backward(rules) {
+		if rule.Pattern.MatchString(file) {
+			return rule.Owners
+		}
+	}
+	return nil
+}
+
+// isOwner returns whether the user is one of the owners, directly or as an active member of a team.
+func (m *Signoff) isOwner(ctx context.Context, user string, owners []string) (bool, error) {
+	for _, owner := range owners {
+		login, ok := strings.CutPrefix(owner, "@")
+		if !ok {
+			// Email addresses can't be matched to the user
+			continue
+		}
+		org, team, isTeam := strings.Cut(login, "/")
+		if !isTeam {
+			if strings.EqualFold(login, user) {
+				return true, nil
+			}
+			continue
+		}
+		out, err := m.WithGhExec([]string{
+			"api", fmt.Sprintf("orgs/%s/teams/%s/memberships/%s", org, team, user), "--jq", ".state",
+		}).Out(ctx)
+		if err != nil {
+			if strings.Contains(out, "(HTTP 404)") {
+				m.debug("%s is not a member of %s", user, owner)
+				continue
+			}
+			return false, fmt.Errorf("could not check if %s is a member of %s, the token requires the 'read:org' scope: %w\n%s", user, owner, err, out)
+		}
+		if strings.TrimSpace(out) == "active" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
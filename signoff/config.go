@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"dagger/signoff/internal/dagger"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Files containing the signoff policy of a repository, in order of precedence
+var configFiles = []string{".signoff.yaml", ".signoff.yml", ".signoff.json"}
+
+// config is the signoff policy of a repository, read from the first of
+// .signoff.yaml, .signoff.yml or .signoff.json found at the root of the sources.
+// Its schema is documented in the module description.
+// Parameters explicitly set on the command line override the file.
+type config struct {
+	CheckName         string `yaml:"checkName"`
+	Branch            string `yaml:"branch"`
+	Owner             string `yaml:"owner"`
+	Repo              string `yaml:"repo"`
+	ScanSecrets       bool   `yaml:"scanSecrets"`
+	RequireCodeowners bool   `yaml:"requireCodeowners"`
+}
+
+// readConfig reads the signoff policy of the sources. An empty config is
+// returned if there is no config file.
+func readConfig(ctx context.Context, sources *dagger.Directory) (*config, error) {
+	entries, err := sources.Entries(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not list sources: %w", err)
+	}
+
+	cfg := &config{}
+	for _, name := range configFiles {
+		if !slices.Contains(entries, name) {
+			continue
+		}
+		content, err := sources.File(name).Contents(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("could not read %s: %w", name, err)
+		}
+		// JSON being a subset of YAML, the same parser is used for both
+		if err := yaml.Unmarshal([]byte(content), cfg); err != nil {
+			return nil, fmt.Errorf("could not parse %s: %w", name, err)
+		}
+		break
+	}
+	return cfg, nil
+}
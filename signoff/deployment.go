@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Sign off the current commit with a successful deployment to the environment.
+//
+// This is an alternative to the commit status created by create, for
+// repositories gating on deployments. A deployment of the current commit is
+// created, followed by a success deployment status, once the repository is
+// clean.
+//
+// The token requires the 'repo_deployment' scope, or 'repo' for private
+// repositories. Fine-grained tokens require the 'Deployments' read and write
+// permission.
+func (m *Signoff) CreateDeployment(
+	ctx context.Context,
+	// Environment deployed to, for instance 'production'
+	environment string,
+) error {
+	if strings.TrimSpace(environment) == "" {
+		return fmt.Errorf("an environment is required")
+	}
+	if err := m.checkScopes(ctx, scopeDeployment); err != nil {
+		return err
+	}
+
+	if err := m.IsClean(ctx); err != nil {
+		return err
+	}
+
+	sha, err := m.Sha(ctx)
+	if err != nil {
+		return err
+	}
+
+	user, err := m.WhoIs(ctx)
+	if err != nil {
+		return err
+	}
+
+	// The signoff is the deployment itself, it must not wait for other statuses
+	// nor try to merge the default branch.
+	body, err := json.Marshal(map[string]any{
+		"ref":               sha,
+		"environment":       environment,
+		"description":       user + " signed off",
+		"auto_merge":        false,
+		"required_contexts": []string{},
+	})
+	if err != nil {
+		return err
+	}
+
+	out, err := m.withGhExecStdin([]string{
+		"api",
+		"--method", "POST",
+		"repos/:owner/:repo/deployments",
+		"--input", "-",
+	}, string(body)).Out(ctx)
+	if err != nil {
+		return fmt.Errorf("could not create deployment of %s to %s: %w\n%s", sha, environment, err, out)
+	}
+	out, err = m.Stdout(ctx)
+	if err != nil {
+		return err
+	}
+
+	var deployment struct {
+		ID int64 `json:"id"`
+	}
+	if err := json.Unmarshal([]byte(out), &deployment); err != nil {
+		return fmt.Errorf("could not parse deployment: %w", err)
+	}
+
+	out, err = m.WithGhExec([]string{
+		"api",
+		"--method", "POST",
+		fmt.Sprintf("repos/:owner/:repo/deployments/%d/statuses", deployment.ID),
+		"-f", "state=success",
+		"-f", "description=" + user + " signed off",
+	}).Out(ctx)
+	if err != nil {
+		return fmt.Errorf("could not create deployment status of %s to %s: %w\n%s", sha, environment, err, out)
+	}
+
+	m.info("✓ Signed off on %s with a deployment to %s", sha, environment)
+
+	return nil
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"dagger/signoff/internal/dagger"
+)
+
+// Maximum file size accepted by GitHub, in MB
+const defaultMaxFileSizeMB = 100
+
+// Check that no file in the commits exceeds the given size.
+//
+// GitHub rejects files over 100MB, this allows to detect them before
+// signing off and pushing. By default the unpushed commits are checked,
+// the branch must then track a remote one.
+func (m *Signoff) CheckFileSizes(
+	ctx context.Context,
+	// Maximum size of a file in MB, default to 100MB
+	// +optional
+	maxMB int,
+	// Range of commits to check, as accepted by git rev-list
+	// +optional
+	// +default="@{push}..HEAD"
+	commits string,
+) error {
+	if maxMB <= 0 {
+		maxMB = defaultMaxFileSizeMB
+	}
+
+	out, err := m.WithGitExec([]string{"rev-list", "--objects", commits}).Out(ctx)
+	if err != nil {
+		return fmt.Errorf("could not list files of commits %s: %w\n%s", commits, err, out)
+	}
+	objects, err := m.Stdout(ctx)
+	if err != nil {
+		return err
+	}
+	out, err = m.withExecStdin([]string{"git", "cat-file", "--batch-check=%(objecttype) %(objectsize) %(rest)"}, objects).Out(ctx)
+	if err != nil {
+		return fmt.Errorf("could not get the size of the files of commits %s: %w\n%s", commits, err, out)
+	}
+	out, err = m.Stdout(ctx)
+	if err != nil {
+		return err
+	}
+
+	var tooLarge []string
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.SplitN(line, " ", 3)
+		if len(fields) != 3 || fields[0] != "blob" {
+			continue
+		}
+		size, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		if size > int64(maxMB)*1024*1024 {
+			tooLarge = append(tooLarge, fmt.Sprintf("%s (%.1fMB)", fields[2], float64(size)/1024/1024))
+		}
+	}
+
+	if len(tooLarge) > 0 {
+		return fmt.Errorf("found files larger than %dMB:\n- %s", maxMB, strings.Join(tooLarge, "\n- "))
+	}
+	return nil
+}
+
+// Scan commits for accidentally committed secrets, using gitleaks.
+//
+// By default the unpushed commits are scanned. If potential secrets are found,
+// the returned error lists the file, line and rule of each finding.
+func (m *Signoff) ScanSecrets(
+	ctx context.Context,
+	// Range of commits to scan, as accepted by git log
+	// +optional
+	// +default="@{push}..HEAD"
+	commits string,
+) error {
+	ctr := dag.Wolfi().
+		Container(dagger.WolfiContainerOpts{
+			Packages: []string{
+				"git",
+				"gitleaks",
+			},
+		}).
+		WithWorkdir("/work/repo").
+		WithMountedDirectory("/work/repo", m.Sources).
+		WithExec([]string{
+			"gitleaks", "git",
+			"--no-banner",
+			"--redact",
+			"--log-opts", commits,
+			"--report-format", "json",
+			"--report-path", "/tmp/gitleaks.json",
+			"--exit-code", "0",
+			".",
+		})
+
+	report, err := ctr.File("/tmp/gitleaks.json").Contents(ctx)
+	if err != nil {
+		return fmt.Errorf("could not scan commits for secrets: %w", err)
+	}
+
+	var findings []struct {
+		File      string `json:"File"`
+		StartLine int    `json:"StartLine"`
+		RuleID    string `json:"RuleID"`
+		Commit    string `json:"Commit"`
+	}
+	if err := json.Unmarshal([]byte(report), &findings); err != nil {
+		return fmt.Errorf("could not parse gitleaks report: %w", err)
+	}
+
+	if len(findings) > 0 {
+		lines := make([]string, 0, len(findings))
+		for _, f := range findings {
+			lines = append(lines, fmt.Sprintf("%s:%d: %s (commit %.7s)", f.File, f.StartLine, f.RuleID, f.Commit))
+		}
+		return fmt.Errorf("found %d potential secrets:\n- %s", len(findings), strings.Join(lines, "\n- "))
+	}
+	return nil
+}
+
+// checkBranchFileSizes checks the size of the files of the commits of the branch that are
+// not part of the default branch.
+func (m *Signoff) checkBranchFileSizes(ctx context.Context) error {
+	defaultBranch, err := m.DefaultBranch(ctx)
+	if err != nil {
+		return fmt.Errorf("could not get the default branch: %w", err)
+	}
+	return m.CheckFileSizes(ctx, 0, "origin/"+defaultBranch+"..HEAD")
+}
+
+// scanBranchSecrets scans the commits of the branch that are not part of the default branch.
+func (m *Signoff) scanBranchSecrets(ctx context.Context) error {
+	defaultBranch, err := m.DefaultBranch(ctx)
+	if err != nil {
+		return fmt.Errorf("could not get the default branch: %w", err)
+	}
+	return m.ScanSecrets(ctx, "origin/"+defaultBranch+"..HEAD")
+}
+
+// Result of the preflight gates
+type PreflightReport struct {
+	// Whether all the gates passed
+	Passed bool
+	// Result of each gate
+	Gates []*PreflightGate
+}
+
+// Result of a preflight gate
+type PreflightGate struct {
+	// Name of the gate
+	Name string
+	// Whether the gate passed
+	Passed bool
+	// Details explaining the failure, if any
+	Details string
+}
+
+// failures returns the details of the failed gates, one per line.
+func (r *PreflightReport) failures() string {
+	var lines []string
+	for _, gate := range r.Gates {
+		if !gate.Passed {
+			lines = append(lines, fmt.Sprintf("✗ %s: %s", gate.Name, gate.Details))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// markdown returns the report as markdown, one list item per gate with the
+// details of the failed ones in a code block.
+func (r *PreflightReport) markdown() string {
+	var b strings.Builder
+	if r.Passed {
+		b.WriteString("All the preflight gates passed.\n\n")
+	} else {
+		b.WriteString("Some preflight gates failed.\n\n")
+	}
+	for _, gate := range r.Gates {
+		if gate.Passed {
+			fmt.Fprintf(&b, "- ✓ %s\n", gate.Name)
+			continue
+		}
+		fmt.Fprintf(&b, "- ✗ %s\n\n  ```\n  %s\n  ```\n", gate.Name, strings.ReplaceAll(strings.TrimSpace(gate.Details), "\n", "\n  "))
+	}
+	return b.String()
+}
+
+type preflightGate struct {
+	name  string
+	check func(context.Context) error
+}
+
+// Run all the enabled gates and report if the current commit is ready to be signed off.
+//
+// The gates are: the repository is clean, no file of the branch commits is too
+// large for GitHub and, if secret scanning is enabled, no secret is found in the
+// branch commits. The branch commits are the ones not part of the default branch.
+// All the gates are run, even if one of them fails.
+func (m *Signoff) Preflight(ctx context.Context) (*PreflightReport, error) {
+	gates := []preflightGate{
+		{"clean", m.IsClean},
+		{"file sizes", m.checkBranchFileSizes},
+	}
+	if m.SecretScanning {
+		gates = append(gates, preflightGate{"secrets", m.scanBranchSecrets})
+	}
+
+	report := &PreflightReport{Passed: true}
+	for _, gate := range gates {
+		result := &PreflightGate{Name: gate.name, Passed: true}
+		if err := gate.check(ctx); err != nil {
+			result.Passed = false
+			result.Details = err.Error()
+			report.Passed = false
+		}
+		report.Gates = append(report.Gates, result)
+	}
+	return report, nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+)
+
+const (
+	// Only errors are reported
+	verbosityQuiet = iota
+	// Results of the operations are printed
+	verbosityNormal
+	// Executed commands are printed too
+	verbosityDebug
+)
+
+// info prints a message at normal verbosity.
+func (m *Signoff) info(format string, args ...any) {
+	m.logf(verbosityNormal, format, args...)
+}
+
+// warn prints a warning at normal verbosity.
+func (m *Signoff) warn(format string, args ...any) {
+	m.logf(verbosityNormal, format, args...)
+}
+
+// debug prints a message at debug verbosity.
+func (m *Signoff) debug(format string, args ...any) {
+	m.logf(verbosityDebug, format, args...)
+}
+
+func (m *Signoff) logf(level int, format string, args ...any) {
+	if m.Verbosity < level {
+		return
+	}
+	fmt.Printf(format+"\n", args...)
+}
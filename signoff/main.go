@@ -3,18 +3,37 @@
 // by moving the CI back to the developer's machine.
 // This module requires a GitHub token to access the different
 // GitHub APIs.
+//
+// A repository can ship its signoff policy in a .signoff.yaml, .signoff.yml
+// or .signoff.json file at its root, setting the defaults of the parameters.
+// Parameters set explicitly override the file:
+//
+//	checkName: signoff       # name of the check
+//	branch: main             # branch to install or uninstall the signoff requirement on
+//	owner: eunomie           # owner of the GitHub repository, set with repo
+//	repo: daggerverse        # name of the GitHub repository, set with owner
+//	scanSecrets: true        # scan the branch commits for secrets before signing off
+//	requireCodeowners: true  # require the user to own the changed files to sign off
 
 package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"path"
+	"regexp"
+	"slices"
 	"strings"
 	"time"
 
 	"dagger/signoff/internal/dagger"
 )
 
+// Error of the failing gh commands when no token is set
+var errNoToken = errors.New("no GitHub token, set --token, e.g. '--token env:GITHUB_TOKEN' to use the GITHUB_TOKEN environment variable of the host")
+
 type Signoff struct {
 	// Source directory containing the local git clone
 	// +private
@@ -26,25 +45,131 @@ type Signoff struct {
 	Container *dagger.Container
 	// Name of the check, default to 'signoff'
 	CheckName string
+	// Branch to install or uninstall the signoff requirement on, default to the default branch
+	Branch string
+	// Owner of the GitHub repository, default to the one of the origin remote
+	Owner string
+	// Name of the GitHub repository, default to the one of the origin remote
+	Repo string
+	// Verbosity of the output: 0 is quiet, 1 is normal, 2 is debug
+	Verbosity int
+	// Do not configure git to use gh as credential helper
+	SkipGitSetup bool
+	// Scan the branch commits for secrets before signing off
+	SecretScanning bool
+	// Require the user to own the changed files, according to CODEOWNERS, to sign off
+	CodeownersRequired bool
+	// Name used by git to create commits, default to the GitHub user name
+	GitUserName string
+	// Email used by git to create commits, default to the GitHub user noreply email
+	GitUserEmail string
+	// Run all the preflight gates before signing off
+	Strict bool
+	// Webhook notified after a successful signoff
+	// +private
+	WebhookURL *dagger.Secret
+
+	// Default branch of the repository, resolved on first use
+	defaultBranch string
+	// Whether the last executed command is a gh one
+	ghExec bool
+	// Whether the git identity has been configured
+	gitUserConfigured bool
 }
 
 func New(
+	ctx context.Context,
 	// The local directory containing the git clone to work on.
 	sources *dagger.Directory,
-	// The GitHub token to get access to the GitHub APIs
+	// The GitHub token to get access to the GitHub APIs.
+	// The module can't read the environment of the host: use '--token env:GITHUB_TOKEN' to pass its GITHUB_TOKEN variable.
+	// Without any token, only the login function can authenticate to GitHub
+	// +optional
 	token *dagger.Secret,
-	// Name of the check, default to 'signoff'
+	// Name of the check, default to the one of the config file or 'signoff'
 	// +optional
-	// +default="signoff"
 	CheckName string,
-) *Signoff {
+	// Owner of the GitHub repository. If not set, the origin remote will be used
+	// +optional
+	owner string,
+	// Name of the GitHub repository. If not set, the origin remote will be used
+	// +optional
+	repo string,
+	// Verbosity of the output: 0 is quiet, 1 is normal, 2 also prints the executed commands
+	// +optional
+	// +default=1
+	verbosity int,
+	// Do not configure git to use gh as credential helper.
+	// Useful when only posting statuses, without any git push or pull
+	// +optional
+	skipGitSetup bool,
+	// Scan the branch commits for secrets before signing off, default to the config file or false
+	// +optional
+	scanSecrets *bool,
+	// Require the user to own the changed files, according to CODEOWNERS, to sign off. Default to the config file or false
+	// +optional
+	requireCodeowners *bool,
+	// Name used by git to create commits. If not set, the name of the authenticated GitHub user will be used
+	// +optional
+	gitUserName string,
+	// Email used by git to create commits. If not set, the noreply email of the authenticated GitHub user will be used
+	// +optional
+	gitUserEmail string,
+	// Run all the preflight gates (clean, file sizes, secrets if enabled) before signing off
+	// +optional
+	strict bool,
+	// URL of a webhook notified with a JSON payload after a successful signoff, e.g. a Slack or Discord incoming webhook.
+	// A secret as such URLs usually contain a token
+	// +optional
+	webhookURL *dagger.Secret,
+) (*Signoff, error) {
+	cfg, err := readConfig(ctx, sources)
+	if err != nil {
+		return nil, err
+	}
+	if CheckName == "" {
+		CheckName = cfg.CheckName
+	}
+	if CheckName == "" {
+		CheckName = "signoff"
+	}
+	if owner == "" && repo == "" {
+		owner, repo = cfg.Owner, cfg.Repo
+	}
+	secretScanning := cfg.ScanSecrets
+	if scanSecrets != nil {
+		secretScanning = *scanSecrets
+	}
+	codeownersRequired := cfg.RequireCodeowners
+	if requireCodeowners != nil {
+		codeownersRequired = *requireCodeowners
+	}
+
+	if (owner == "") != (repo == "") {
+		return nil, fmt.Errorf("owner and repo must be set together")
+	}
+
 	s := &Signoff{
-		Sources:   sources,
-		Token:     token,
-		CheckName: CheckName,
+		Sources:            sources,
+		Token:              token,
+		CheckName:          CheckName,
+		Branch:             cfg.Branch,
+		Owner:              owner,
+		Repo:               repo,
+		Verbosity:          verbosity,
+		SkipGitSetup:       skipGitSetup,
+		SecretScanning:     secretScanning,
+		CodeownersRequired: codeownersRequired,
+		GitUserName:        gitUserName,
+		GitUserEmail:       gitUserEmail,
+		Strict:             strict,
+		WebhookURL:         webhookURL,
+	}
+	if token == nil {
+		s.warn("⚠ No GitHub token: set --token, e.g. '--token env:GITHUB_TOKEN', or use login to authenticate interactively")
 	}
 	s.Container = s.container()
-	return s
+	return s, nil
 }
 
 // Check if the local directory is clean.
@@ -69,62 +194,446 @@ func (m *Signoff) IsClean(ctx context.Context) error {
 	return nil
 }
 
+// push pushes the current branch to its tracking branch, setting one up on the
+// origin remote if it doesn't exist yet.
+func (m *Signoff) push(ctx context.Context) error {
+	args := []string{"push"}
+	if exitCode, err := m.WithGitExec([]string{"rev-parse", "--abbrev-ref", "@{push}"}).ExitCode(ctx); err != nil || exitCode != 0 {
+		branch, err := m.CurrentBranch(ctx)
+		if err != nil {
+			return err
+		}
+		args = append(args, "--set-upstream", "origin", branch)
+	}
+
+	out, err := m.WithGitExec(args).Out(ctx)
+	if err != nil {
+		return fmt.Errorf("could not push: %w\n%s", err, out)
+	}
+	m.info("✓ Pushed the current branch")
+	return nil
+}
+
 // Sign off the current commit.
 //
 // This first ensures the repository is clean, then
 // mark the status of the signoff check (or any other configured
 // name) as success.
-func (m *Signoff) Create(ctx context.Context) error {
+// If secret scanning is enabled, the commits of the branch that are not
+// part of the default branch are scanned first.
+// In strict mode, all the preflight gates must pass instead.
+// When code owners are required, the user must own the changed files, even
+// when forcing.
+//
+// The returned message confirms the signed off commit, it is not printed so
+// it can be captured or logged by the caller.
+//
+// Forcing skips the clean checks: the signed off commit may then not contain
+// the local changes or not even exist on GitHub. Use it only when cleanliness
+// has already been validated. A reason must be given, it is recorded in the
+// status description for audit.
+//
+// Co-authors, for pair or mob programming, are recorded in the status description
+// along with the authenticated user. Each login must be an existing GitHub user,
+// attach-note records them as 'Co-authored-by' trailers.
+//
+// Pushing first sends the local commits to the tracking branch, set up on the
+// origin remote if needed, before checking the repository is clean.
+//
+// Skipping signed off commits makes the call idempotent, avoiding a new status
+// when the check is already successful on the current commit.
+//
+// Once signed off, the configured webhook is notified. A failing notification
+// is reported without failing the signoff.
+func (m *Signoff) Create(
+	ctx context.Context,
+	// Skip the checks ensuring the repository is clean
+	// +optional
+	force bool,
+	// Justification of a forced signoff, recorded in the status description. Required when forcing
+	// +optional
+	reason string,
+	// Push the current branch to its tracking branch before running the clean checks
+	// +optional
+	pushFirst bool,
+	// GitHub logins of the co-authors, recorded in the status description
+	// +optional
+	coAuthors []string,
+	// Do nothing if the current commit is already signed off
+	// +optional
+	skipSignedOff bool,
+) (string, error) {
+	if force && strings.TrimSpace(reason) == "" {
+		return "", fmt.Errorf("a reason is required to force the signoff")
+	}
+
+	if err := m.checkScopes(ctx, scopeStatus); err != nil {
+		return "", err
+	}
+
+	if skipSignedOff {
+		signedOff, err := m.IsSignedOff(ctx)
+		if err != nil {
+			return "", err
+		}
+		if signedOff {
+			sha, err := m.Sha(ctx)
+			if err != nil {
+				return "", err
+			}
+			return "✓ Already signed off on " + sha, nil
+		}
+	}
+
+	for _, login := range coAuthors {
+		if _, err := m.coAuthor(ctx, login); err != nil {
+			return "", err
+		}
+	}
+
+	if m.CodeownersRequired {
+		if err := m.RequireCodeowners(ctx); err != nil {
+			return "", err
+		}
+	}
+
+	if pushFirst {
+		if err := m.push(ctx); err != nil {
+			return "", err
+		}
+	}
+
+	switch {
+	case force:
+		m.warn("⚠ Forcing signoff, skipping the clean checks")
+	case m.Strict:
+		report, err := m.Preflight(ctx)
+		if err != nil {
+			return "", err
+		}
+		if !report.Passed {
+			return "", fmt.Errorf("preflight failed:\n%s", report.failures())
+		}
+	default:
+		if err := m.IsClean(ctx); err != nil {
+			return "", err
+		}
+		if m.SecretScanning {
+			if err := m.scanBranchSecrets(ctx); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	sha, err := m.Sha(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	user, err := m.WhoIs(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	description := user + " signed off"
+	if len(coAuthors) > 0 {
+		description += " with " + strings.Join(coAuthors, ", ")
+	}
+	if force {
+		description += " (forced: " + strings.TrimSpace(reason) + ")"
+	}
+	if err := m.createStatus(ctx, sha, description); err != nil {
+		return "", err
+	}
+	m.notify(ctx, sha, user)
+
+	return "✓ Signed off on " + sha, nil
+}
+
+// Relative date accepted by git, e.g. '2 weeks ago'
+var relativeDateRe = regexp.MustCompile(`^\d+\s*(?:second|minute|hour|day|week|month|year)s?\s+ago$`)
+
+// Sign off all the commits of the current branch since the base, in a single call.
+//
+// The commits must be pushed, as for Create. Every commit is processed even if
+// some of them fail, the failures being reported in the returned error.
+//
+// Since limits the commits to the ones committed after a date, an RFC 3339
+// timestamp ('2024-01-02T15:04:05Z'), a day ('2024-01-02'), 'yesterday' or
+// a relative date ('2 weeks ago'). Other formats are rejected as git would
+// silently take them as the current time.
+func (m *Signoff) CreateRange(
+	ctx context.Context,
+	// Base revision, default to the default branch of the origin remote
+	// +optional
+	base string,
+	// Only sign off the commits committed after this date
+	// +optional
+	since string,
+) error {
+	if since != "" && !validSince(since) {
+		return fmt.Errorf("invalid since %q, must be an RFC 3339 timestamp, a 'YYYY-MM-DD' day, 'yesterday' or a relative date like '2 weeks ago'", since)
+	}
+
+	if err := m.checkScopes(ctx, scopeStatus); err != nil {
+		return err
+	}
 	if err := m.IsClean(ctx); err != nil {
 		return err
 	}
 
-	sha, err := m.Sha(ctx)
+	if base == "" {
+		defaultBranch, err := m.DefaultBranch(ctx)
+		if err != nil {
+			return fmt.Errorf("could not get the default branch: %w", err)
+		}
+		base = "origin/" + defaultBranch
+	}
+	args := []string{"log", "--format=%H"}
+	if since != "" {
+		args = append(args, "--since="+since)
+	}
+	out, err := m.WithGitExec(append(args, base+"..HEAD")).Out(ctx)
+	if err != nil {
+		return fmt.Errorf("could not list the commits since %s: %w\n%s", base, err, out)
+	}
+	out, err = m.Stdout(ctx)
 	if err != nil {
 		return err
 	}
+	shas := strings.Fields(out)
+	if len(shas) == 0 {
+		m.info("No commit to sign off")
+		return nil
+	}
 
 	user, err := m.WhoIs(ctx)
 	if err != nil {
 		return err
 	}
+	var failed []string
+	for _, sha := range shas {
+		if err := m.createStatus(ctx, sha, user+" signed off"); err != nil {
+			m.info("✗ Could not sign off %s: %v", sha, err)
+			failed = append(failed, sha)
+			continue
+		}
+		m.info("✓ Signed off on %s", sha)
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("could not sign off %d of %d commits: %s", len(failed), len(shas), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// validSince returns whether the date is in one of the formats accepted by CreateRange.
+func validSince(since string) bool {
+	since = strings.TrimSpace(since)
+	if _, err := time.Parse(time.RFC3339, since); err == nil {
+		return true
+	}
+	if _, err := time.Parse(time.DateOnly, since); err == nil {
+		return true
+	}
+	return since == "yesterday" || relativeDateRe.MatchString(since)
+}
+
+// Maximum length of a commit status description accepted by GitHub
+const statusDescriptionLimit = 140
+
+// createStatus marks the status of the signoff check as success on the commit.
+func (m *Signoff) createStatus(ctx context.Context, sha, description string) error {
+	return m.createStatusOn(ctx, ":owner/:repo", sha, description)
+}
 
+// createStatusOn marks the status of the signoff check as success on the commit of the repository.
+// The description is truncated to the length accepted by GitHub.
+func (m *Signoff) createStatusOn(ctx context.Context, repo, sha, description string) error {
+	if r := []rune(description); len(r) > statusDescriptionLimit {
+		description = string(r[:statusDescriptionLimit-1]) + "…"
+	}
 	out, err := m.WithGhExec([]string{
 		"api",
 		"--method", "POST",
-		"repos/:owner/:repo/statuses/" + sha,
+		"repos/" + repo + "/statuses/" + sha,
 		"-f", "state=success",
 		"-f", "context=" + m.CheckName,
-		"-f", fmt.Sprintf("description=\"%s signed off\"", user),
+		"-f", "description=" + description,
 	}).Out(ctx)
 
 	if err != nil {
 		return fmt.Errorf("%s: %w", out, err)
 	}
+	return nil
+}
 
-	fmt.Println("✓ Signed off on " + sha)
+// Repository name, 'owner/repo'
+var repoRe = regexp.MustCompile(`^[\w.-]+/[\w.-]+$`)
+
+// Sign off a commit of any repository, not necessarily the one of the sources.
+//
+// This marks the status of the signoff check as success on the given commit,
+// without any check on the local repository.
+func (m *Signoff) CreateOn(
+	ctx context.Context,
+	// Repository, 'owner/repo'
+	repo string,
+	// Full SHA of the commit to sign off
+	sha string,
+) error {
+	if !repoRe.MatchString(repo) {
+		return fmt.Errorf("invalid repository %q, must be 'owner/repo'", repo)
+	}
+	if err := m.checkScopesOn(ctx, repo, sha, scopeStatus); err != nil {
+		return err
+	}
+
+	user, err := m.WhoIs(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := m.createStatusOn(ctx, repo, sha, user+" signed off"); err != nil {
+		if strings.Contains(err.Error(), "HTTP 404") {
+			return fmt.Errorf("repository %q or commit %s not found, or not accessible with the token", repo, sha)
+		}
+		return err
+	}
+
+	m.info("✓ Signed off on %s@%s", repo, sha)
 
 	return nil
 }
 
+// Full commit SHA, the statuses API doesn't accept abbreviated ones
+var shaRe = regexp.MustCompile(`^[0-9a-fA-F]{40}$`)
+
+// Sign off a list of commits, using the GitHub API only.
+//
+// The file contains one full commit SHA per line, empty lines and lines starting
+// with '#' are ignored. This is useful to backfill the statuses of existing
+// commits when starting to require signoff.
+// Every commit is processed even if some of them fail, the failures being
+// reported in the returned error.
+func (m *Signoff) CreateFromFile(ctx context.Context, file dagger.File) error {
+	c, err := file.Contents(ctx)
+	if err != nil {
+		return fmt.Errorf("could not read file: %w", err)
+	}
+
+	var shas []string
+	for i, line := range strings.Split(c, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !shaRe.MatchString(line) {
+			return fmt.Errorf("line %d: %q is not a full commit SHA of 40 hexadecimal characters", i+1, line)
+		}
+		shas = append(shas, line)
+	}
+	if len(shas) == 0 {
+		return fmt.Errorf("no commit SHA found in the file")
+	}
+
+	// The permissions being the same for all the commits of the repository, the first one is enough
+	if err := m.checkScopesOn(ctx, ":owner/:repo", shas[0], scopeStatus); err != nil {
+		return err
+	}
+
+	user, err := m.WhoIs(ctx)
+	if err != nil {
+		return err
+	}
+
+	var failed []string
+	for _, sha := range shas {
+		if err := m.createStatus(ctx, sha, user+" signed off"); err != nil {
+			m.info("✗ Could not sign off %s: %v", sha, err)
+			failed = append(failed, sha)
+			continue
+		}
+		m.info("✓ Signed off on %s", sha)
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("could not sign off %d of %d commits: %s", len(failed), len(shas), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// Sign off the commit of a tag, typically for release signoffs.
+//
+// The tag must exist in the local repository, the status is created on the
+// commit it points to.
+func (m *Signoff) SignoffTag(ctx context.Context, tag string) error {
+	if exitCode, err := m.WithGitExec([]string{"rev-parse", "--verify", "--quiet", "refs/tags/" + tag}).ExitCode(ctx); err != nil || exitCode != 0 {
+		return fmt.Errorf("tag %q not found", tag)
+	}
+
+	out, err := m.WithGitExec([]string{"rev-list", "-n", "1", tag}).Out(ctx)
+	if err != nil {
+		return fmt.Errorf("could not resolve tag %q: %w\n%s", tag, err, out)
+	}
+	out, err = m.Stdout(ctx)
+	if err != nil {
+		return err
+	}
+	sha := strings.TrimSpace(out)
+
+	if err := m.checkScopes(ctx, scopeStatus); err != nil {
+		return err
+	}
+
+	user, err := m.WhoIs(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := m.createStatus(ctx, sha, user+" signed off "+tag); err != nil {
+		return err
+	}
+
+	m.info("✓ Signed off on %s (%s)", tag, sha)
+
+	return nil
+}
+
+// targetBranch returns the branch to work on: the given one, the configured one or the default one.
+func (m *Signoff) targetBranch(ctx context.Context, branch string) (string, error) {
+	if branch != "" {
+		return branch, nil
+	}
+	if m.Branch != "" {
+		return m.Branch, nil
+	}
+	branch, err := m.DefaultBranch(ctx)
+	if err != nil {
+		return "", fmt.Errorf("could not get the default branch: %w", err)
+	}
+	return branch, nil
+}
+
 // Install signoff requirement on the defined branch or on the default one
 func (m *Signoff) Install(
 	ctx context.Context,
-	// Branch to install the signoff requirement. If not set, the default branch will be used
+	// Branch to install the signoff requirement. If not set, the configured or default branch will be used
 	// +optional
 	branch string,
 ) error {
-	if branch == "" {
-		var err error
-		if branch, err = m.DefaultBranch(ctx); err != nil {
-			return fmt.Errorf("could not get the default branch: %w", err)
-		}
+	branch, err := m.targetBranch(ctx, branch)
+	if err != nil {
+		return err
 	}
-
 	if branch == "" {
 		return fmt.Errorf("could not install without a branch name")
 	}
 
+	if err := m.checkScopes(ctx, scopeRepo); err != nil {
+		return err
+	}
+
 	out, err := m.WithGhExec([]string{
 		"api",
 		fmt.Sprintf("/repos/:owner/:repo/branches/%s/protection", branch),
@@ -141,7 +650,7 @@ func (m *Signoff) Install(
 		return fmt.Errorf("could not install signoff check %q to branch %q: %w\n%s", m.CheckName, branch, err, out)
 	}
 
-	fmt.Printf("✓ GitHub %s branch now requires signoff on check %q", m.CheckName, branch)
+	m.info("✓ GitHub %s branch now requires signoff on check %q", branch, m.CheckName)
 
 	return nil
 }
@@ -150,17 +659,14 @@ func (m *Signoff) Install(
 // This will delete all branch protection on the selected branch.
 func (m *Signoff) Uninstall(
 	ctx context.Context,
-	// Branch to uninstall the signoff requirement. If not set, the default branch will be used
+	// Branch to uninstall the signoff requirement. If not set, the configured or default branch will be used
 	// +optional
 	branch string,
 ) error {
-	if branch == "" {
-		var err error
-		if branch, err = m.DefaultBranch(ctx); err != nil {
-			return fmt.Errorf("could not get the default branch: %w", err)
-		}
+	branch, err := m.targetBranch(ctx, branch)
+	if err != nil {
+		return err
 	}
-
 	if branch == "" {
 		return fmt.Errorf("could not uninstall without a branch name")
 	}
@@ -174,7 +680,207 @@ func (m *Signoff) Uninstall(
 		return fmt.Errorf("could not uninstall branch protection for branch %q: %w\n%s", branch, err, out)
 	}
 
-	fmt.Printf("✓ GitHub %s branch no longer requires signoff", m.CheckName)
+	m.info("✓ GitHub %s branch no longer requires signoff", branch)
+
+	return nil
+}
+
+// Configure the protection of the defined branch or of the default one to require
+// only the signoff check and nothing else: no other check, no review, no push
+// restriction and no enforcement for admins.
+//
+// This is the lightweight setup of solo developer repositories. The existing
+// protection of the branch is replaced, and read back to verify each of those
+// settings has been applied.
+func (m *Signoff) SetStatusOnly(
+	ctx context.Context,
+	// Branch to configure. If not set, the configured or default branch will be used
+	// +optional
+	branch string,
+) error {
+	branch, err := m.targetBranch(ctx, branch)
+	if err != nil {
+		return err
+	}
+	if branch == "" {
+		return fmt.Errorf("could not configure without a branch name")
+	}
+
+	if err := m.checkScopes(ctx, scopeRepo); err != nil {
+		return err
+	}
+
+	// Marshaling a map of strings and booleans can't fail
+	body, _ := json.Marshal(map[string]any{
+		"required_status_checks": map[string]any{
+			"strict":   false,
+			"contexts": []string{m.CheckName},
+		},
+		"enforce_admins":                false,
+		"required_pull_request_reviews": nil,
+		"restrictions":                  nil,
+	})
+	out, err := m.withGhExecStdin([]string{
+		"api",
+		fmt.Sprintf("/repos/:owner/:repo/branches/%s/protection", branch),
+		"--method", "PUT",
+		"-H", "Accept: application/vnd.github+json",
+		"-H", "X-GitHub-Api-Version: 2022-11-28",
+		"--input", "-",
+	}, string(body)).Out(ctx)
+	if err != nil {
+		return fmt.Errorf("could not set branch %q protection: %w\n%s", branch, err, out)
+	}
+
+	// The policy of Install is the same: only the signoff check, no strict mode,
+	// no admin enforcement, no review and no restriction
+	diff, err := m.policyDiff(ctx, branch)
+	if err != nil {
+		return err
+	}
+	if len(diff) > 0 {
+		return fmt.Errorf("branch %q protection was not applied:\n%s", branch, strings.Join(diff, "\n"))
+	}
+
+	m.info("✓ GitHub %s branch only requires the %q check", branch, m.CheckName)
+
+	return nil
+}
+
+// Get the status checks required by the protection of the defined branch or of the default one.
+//
+// An unprotected branch, or a branch not requiring any status check, returns an empty list.
+func (m *Signoff) RequiredChecks(
+	ctx context.Context,
+	// Branch to get the required checks of. If not set, the configured or default branch will be used
+	// +optional
+	branch string,
+) ([]string, error) {
+	branch, err := m.targetBranch(ctx, branch)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := m.WithGhExec([]string{
+		"api",
+		fmt.Sprintf("/repos/:owner/:repo/branches/%s/protection/required_status_checks", branch),
+		"--jq", ".contexts[]",
+	}).Out(ctx)
+	if err != nil {
+		if strings.Contains(out, "HTTP 404") {
+			return []string{}, nil
+		}
+		return nil, fmt.Errorf("could not get the required checks of branch %q: %w\n%s", branch, err, out)
+	}
+	out, err = m.Stdout(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check names may contain spaces, one per line
+	checks := []string{}
+	for _, line := range strings.Split(out, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			checks = append(checks, line)
+		}
+	}
+	return checks, nil
+}
+
+// List the branches of the repository matching a branch protection pattern.
+//
+// Patterns use the fnmatch syntax of GitHub branch protection rules, where '*'
+// doesn't match '/' (e.g. 'release/*') and '**' does (e.g. 'release/**'). This
+// previews the branches a rule would apply to, before creating it.
+func (m *Signoff) MatchingBranches(ctx context.Context, pattern string) ([]string, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid branch pattern %q: %w", pattern, err)
+	}
+
+	out, err := m.WithGhExec([]string{
+		"api", "--paginate",
+		"repos/:owner/:repo/branches",
+		"--jq", ".[].name",
+	}).Out(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not list the branches: %w\n%s", err, out)
+	}
+	out, err = m.Stdout(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	branches := []string{}
+	for _, branch := range strings.Fields(out) {
+		if matchBranch(pattern, branch) {
+			branches = append(branches, branch)
+		}
+	}
+	return branches, nil
+}
+
+// matchBranch returns whether the branch matches the pattern, '**' matching any
+// characters including '/' and '**/' also matching no directory at all.
+// The pattern must be valid for path.Match.
+func matchBranch(pattern, branch string) bool {
+	before, after, found := strings.Cut(pattern, "**")
+	if !found {
+		ok, _ := path.Match(pattern, branch)
+		return ok
+	}
+	for i := 0; i <= len(branch); i++ {
+		if ok, _ := path.Match(before, branch[:i]); !ok {
+			continue
+		}
+		if strings.HasPrefix(after, "/") && matchBranch(after[1:], branch[i:]) {
+			return true
+		}
+		for j := i; j <= len(branch); j++ {
+			if matchBranch(after, branch[j:]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Re-run the failed GitHub Actions runs of the current commit.
+//
+// Only the failed jobs of each run are re-triggered.
+func (m *Signoff) RerunChecks(ctx context.Context) error {
+	sha, err := m.Sha(ctx)
+	if err != nil {
+		return err
+	}
+
+	out, err := m.WithGhExec([]string{
+		"run", "list",
+		"--commit", sha,
+		"--status", "failure",
+		"--json", "databaseId",
+		"--jq", ".[].databaseId",
+	}).Out(ctx)
+	if err != nil {
+		return fmt.Errorf("could not list failed runs for %s: %w\n%s", sha, err, out)
+	}
+	out, err = m.Stdout(ctx)
+	if err != nil {
+		return err
+	}
+
+	runs := strings.Fields(out)
+	if len(runs) == 0 {
+		m.info("No failed runs found on %s", sha)
+		return nil
+	}
+
+	for _, run := range runs {
+		out, err := m.WithGhExec([]string{"run", "rerun", run, "--failed"}).Out(ctx)
+		if err != nil {
+			return fmt.Errorf("could not re-run run %s: %w\n%s", run, err, out)
+		}
+		m.info("✓ Re-triggered run %s", run)
+	}
 
 	return nil
 }
@@ -188,6 +894,68 @@ func (m *Signoff) Sha(ctx context.Context) (string, error) {
 	return strings.TrimSpace(out), nil
 }
 
+// Get the name of the current branch.
+func (m *Signoff) CurrentBranch(ctx context.Context) (string, error) {
+	out, err := m.WithGitExec([]string{"rev-parse", "--abbrev-ref", "HEAD"}).Out(ctx)
+	if err != nil {
+		return "", fmt.Errorf("could not get the current branch: %w\n%s", err, out)
+	}
+	out, err = m.Stdout(ctx)
+	if err != nil {
+		return "", err
+	}
+	branch := strings.TrimSpace(out)
+	if branch == "HEAD" {
+		return "", fmt.Errorf("HEAD is detached, not on a branch")
+	}
+	return branch, nil
+}
+
+// Check if the authenticated user has push access to the repository.
+//
+// Tokens without access to the repository permissions, like some GitHub App
+// tokens, can't be checked and return an error.
+func (m *Signoff) CanPush(ctx context.Context) (bool, error) {
+	out, err := m.WithGhExec([]string{
+		"api",
+		"repos/:owner/:repo",
+		"--jq", ".permissions.push",
+	}).Out(ctx)
+	if err != nil {
+		return false, fmt.Errorf("could not get the repository permissions: %w\n%s", err, out)
+	}
+	out, err = m.Stdout(ctx)
+	if err != nil {
+		return false, err
+	}
+	switch strings.TrimSpace(out) {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	default:
+		return false, fmt.Errorf("the repository permissions are not available for the token")
+	}
+}
+
+// coAuthor returns the identity of the GitHub user as a co-author of a commit, 'name <email>',
+// the name being the login if not set and the email the noreply one of the user.
+// An error is returned if the user doesn't exist.
+func (m *Signoff) coAuthor(ctx context.Context, login string) (string, error) {
+	out, err := m.WithGhExec([]string{
+		"api", "users/" + login,
+		"--jq", `"\(.name // .login) <\(.id)+\(.login)@users.noreply.github.com>"`,
+	}).Out(ctx)
+	if err != nil {
+		return "", fmt.Errorf("could not find GitHub user %q: %w\n%s", login, err, out)
+	}
+	out, err = m.Stdout(ctx)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
 // Get the username of the user who is currently authenticated
 func (m *Signoff) WhoIs(ctx context.Context) (string, error) {
 	out, err := m.WithGhExec([]string{
@@ -217,28 +985,92 @@ func (m *Signoff) PullRequest(ctx context.Context) (string, error) {
 	return strings.TrimSpace(out), nil
 }
 
+// Get the url of the pull request associated to a commit, empty if there is none.
+//
+// When the commit is part of several pull requests, the first open one is
+// returned, or the first one if they are all closed.
+func (m *Signoff) PullRequestForSha(ctx context.Context, sha string) (string, error) {
+	out, err := m.WithGhExec([]string{
+		"api",
+		"repos/:owner/:repo/commits/" + sha + "/pulls",
+		"--jq", `([.[] | select(.state == "open")] + .) | .[0].html_url // ""`,
+	}).Out(ctx)
+	if err != nil {
+		return "", fmt.Errorf("could not get the pull requests of %s: %w\n%s", sha, err, out)
+	}
+	out, err = m.Stdout(ctx)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// Get the url of the pull request of the current branch, on a single line.
+//
+// The output contains only the url so it can be piped to 'xdg-open' or 'open'.
+func (m *Signoff) PrintPR(ctx context.Context) (string, error) {
+	out, err := m.WithGhExec([]string{
+		"pr", "view",
+		"--json", "url",
+		"--jq", ".url",
+	}).Out(ctx)
+	if err != nil {
+		if strings.Contains(out, "no pull requests found") {
+			return "", fmt.Errorf("no pull request found for the current branch")
+		}
+		return "", fmt.Errorf("could not get the pull request of the current branch: %w\n%s", err, out)
+	}
+	out, err = m.Stdout(ctx)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
 // Open a pull request for the current branch
+//
+// Unless a body is given, the pull request template of the repository is used
+// as body, the title being filled from the commits. Without template, both
+// are filled from the commits.
 func (m *Signoff) OpenPR(
 	ctx context.Context,
 	// fill with verbose information
 	// +optional
 	// +default=false
 	verbose bool,
+	// Body of the pull request, instead of the template
+	// +optional
+	body string,
+	// Name of the template to use, among the ones of a 'PULL_REQUEST_TEMPLATE' directory
+	// +optional
+	template string,
 ) (string, error) {
 	fill := "--fill"
 	if verbose {
 		fill = "--fill-verbose"
 	}
-	return m.WithGhExec([]string{
+	if body == "" {
+		var err error
+		if body, err = m.prTemplate(ctx, template); err != nil {
+			return "", err
+		}
+	}
+	args := []string{
 		"pr",
 		"create",
 		fill,
-	}).Out(ctx)
+	}
+	if body != "" {
+		args = append(args, "--body", body)
+	}
+	return m.WithGhExec(args).Out(ctx)
 }
 
 // Exec any command
 func (m *Signoff) WithExec(args []string) *Signoff {
+	m.debug("$ %s", strings.Join(args, " "))
 	m.Container = m.Container.WithExec(args, dagger.ContainerWithExecOpts{Expect: dagger.ReturnTypeAny})
+	m.ghExec = false
 	return m
 }
 
@@ -247,9 +1079,100 @@ func (m *Signoff) WithGitExec(args []string) *Signoff {
 	return m.WithExec(append([]string{"git"}, args...))
 }
 
-// Exec any gh command. 'gh' will be automatically added to the arguments
+// Exec any gh command. 'gh' will be automatically added to the arguments.
+// If owner and repo are configured, ':owner/:repo' placeholders are replaced by them.
 func (m *Signoff) WithGhExec(args []string) *Signoff {
-	return m.WithExec(append([]string{"gh"}, args...))
+	m.WithExec(m.ghCommand(args))
+	m.ghExec = true
+	return m
+}
+
+// withExecStdin execs a command, like WithExec, with the given standard input.
+func (m *Signoff) withExecStdin(args []string, stdin string) *Signoff {
+	m.debug("$ %s", strings.Join(args, " "))
+	m.Container = m.Container.WithExec(args, dagger.ContainerWithExecOpts{Stdin: stdin, Expect: dagger.ReturnTypeAny})
+	m.ghExec = false
+	return m
+}
+
+// withGhExecStdin execs a gh command, like WithGhExec, with the given standard input.
+func (m *Signoff) withGhExecStdin(args []string, stdin string) *Signoff {
+	m.withExecStdin(m.ghCommand(args), stdin)
+	m.ghExec = true
+	return m
+}
+
+func (m *Signoff) ghCommand(args []string) []string {
+	cmd := []string{"gh"}
+	for _, arg := range args {
+		if m.Owner != "" {
+			arg = strings.ReplaceAll(arg, ":owner/:repo", m.Owner+"/"+m.Repo)
+		}
+		cmd = append(cmd, arg)
+	}
+	return cmd
+}
+
+// Get the container with git and gh tools, authenticated to GitHub.
+//
+// This is the same container the module uses internally: the token is set,
+// git is configured and the sources are mounted in the working directory.
+// It can be used to run any other git or gh command.
+func (m *Signoff) AuthenticatedContainer() *dagger.Container {
+	return m.Container
+}
+
+// Variable names whose values are redacted by Env
+var secretEnvRe = regexp.MustCompile(`(?i)(TOKEN|SECRET|PASSWORD)$`)
+
+// Values looking like GitHub tokens, redacted by Env whatever the variable name
+var githubTokenRe = regexp.MustCompile(`\b(?:gh[pousr]_|github_pat_)\w+`)
+
+// Get the environment variables of the container, to troubleshoot authentication issues.
+//
+// The values of the variables whose names end with TOKEN, SECRET or PASSWORD,
+// like GITHUB_TOKEN, are redacted, as well as any value looking like a GitHub
+// token. Empty values are kept, to tell a missing token from a set one. The
+// variables are sorted by name.
+func (m *Signoff) Env(ctx context.Context) (string, error) {
+	out, err := m.WithExec([]string{"env"}).Out(ctx)
+	if err != nil {
+		return "", fmt.Errorf("could not get the environment: %w\n%s", err, out)
+	}
+	out, err = m.Stdout(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var vars []string
+	for _, line := range strings.Split(out, "\n") {
+		name, value, found := strings.Cut(line, "=")
+		if !found {
+			continue
+		}
+		if secretEnvRe.MatchString(name) && value != "" {
+			value = "***"
+		}
+		vars = append(vars, name+"="+githubTokenRe.ReplaceAllString(value, "***"))
+	}
+	slices.Sort(vars)
+	return strings.Join(vars, "\n") + "\n", nil
+}
+
+// Get a container to authenticate interactively to GitHub, without a token.
+//
+// Run 'gh auth login' in its terminal, for instance with 'dagger call login terminal'.
+// gh then prompts for the account and the authentication method, the prompts being
+// disabled in the other containers.
+// The credentials only live in this container, for the duration of the session:
+// they are not stored on the host nor reused by other calls.
+func (m *Signoff) Login() *dagger.Container {
+	return m.base().
+		// gh auth login requires --web or --with-token when the prompts are disabled
+		WithoutEnvVariable("GH_PROMPT_DISABLED").
+		WithWorkdir("/work/repo").
+		WithMountedDirectory("/work/repo", m.Sources).
+		WithDefaultTerminalCmd([]string{"gh", "auth", "login"})
 }
 
 // Open an interactive terminal into the container with git and gh tools
@@ -272,6 +1195,9 @@ func (m *Signoff) Out(ctx context.Context) (string, error) {
 		return "", err
 	}
 	if exitCode != 0 {
+		if m.ghExec && m.Token == nil {
+			return out, fmt.Errorf("exit code %d: %w", exitCode, errNoToken)
+		}
 		return out, fmt.Errorf("exit code %d", exitCode)
 	}
 	return out, nil
@@ -289,21 +1215,43 @@ func (m *Signoff) Stderr(ctx context.Context) (string, error) {
 	return m.Container.Stderr(ctx)
 }
 
-// Get the default branch configured on the repository using gh API
+// Get the default branch configured on the repository using gh API.
+//
+// If the API can't be reached, the default branch is read from the local
+// origin remote HEAD. The result is kept for the next calls.
 func (m *Signoff) DefaultBranch(ctx context.Context) (string, error) {
+	if m.defaultBranch != "" {
+		return m.defaultBranch, nil
+	}
+
 	out, err := m.WithGhExec([]string{
 		"api",
 		"repos/:owner/:repo",
 		"--jq", ".default_branch",
-	}).Stdout(ctx)
+	}).Out(ctx)
+	if err == nil {
+		out, err = m.Stdout(ctx)
+	} else {
+		m.debug("could not get the default branch from the API, using the origin remote HEAD: %v\n%s", err, out)
+		if out, err = m.WithGitExec([]string{"symbolic-ref", "--short", "refs/remotes/origin/HEAD"}).Out(ctx); err != nil {
+			return "", fmt.Errorf("could not get the default branch from the API nor the origin remote HEAD: %w\n%s", err, out)
+		}
+		out, err = m.Stdout(ctx)
+		out = strings.TrimPrefix(strings.TrimSpace(out), "origin/")
+	}
 	if err != nil {
 		return "", err
 	}
-	return strings.TrimSpace(out), nil
+	branch := strings.TrimSpace(out)
+	if branch == "" {
+		return "", fmt.Errorf("could not get the default branch: empty branch name")
+	}
+	m.defaultBranch = branch
+	return m.defaultBranch, nil
 }
 
 func (m *Signoff) base() *dagger.Container {
-	return dag.Wolfi().
+	ctr := dag.Wolfi().
 		Container(dagger.WolfiContainerOpts{
 			Packages: []string{
 				"gh",
@@ -311,14 +1259,66 @@ func (m *Signoff) base() *dagger.Container {
 			},
 		}).
 		WithEnvVariable("GH_PROMPT_DISABLED", "true").
-		WithEnvVariable("GH_NO_UPDATE_NOTIFIER", "true").
-		WithExec([]string{"gh", "auth", "setup-git", "--force", "--hostname", "github.com"}) // Use force to avoid network call and cache setup even when no token is provided.
+		WithEnvVariable("GH_NO_UPDATE_NOTIFIER", "true")
+	if m.SkipGitSetup {
+		return ctr
+	}
+	return ctr.WithExec([]string{"gh", "auth", "setup-git", "--force", "--hostname", "github.com"}) // Use force to avoid network call and cache setup even when no token is provided.
 }
 
 func (m *Signoff) container() *dagger.Container {
-	return m.base().
+	ctr := m.base().
 		WithEnvVariable("CACHE_BUSTER", time.Now().Format(time.RFC3339Nano)).
-		WithSecretVariable("GITHUB_TOKEN", m.Token).
 		WithWorkdir("/work/repo").
 		WithMountedDirectory("/work/repo", m.Sources)
+	if m.Token != nil {
+		ctr = ctr.WithSecretVariable("GITHUB_TOKEN", m.Token)
+	}
+	if m.Owner != "" {
+		// Target the explicit repository for gh commands not using the api placeholders.
+		ctr = ctr.WithEnvVariable("GH_REPO", m.Owner+"/"+m.Repo)
+	}
+	return ctr
+}
+
+// configureGitUser configures the git identity used to create commits, once.
+// The GitHub user is only queried when the name or the email is not set.
+func (m *Signoff) configureGitUser(ctx context.Context) error {
+	if m.gitUserConfigured {
+		return nil
+	}
+
+	name, email := m.GitUserName, m.GitUserEmail
+	if name == "" || email == "" {
+		out, err := m.WithGhExec([]string{
+			"api", "user",
+			"--jq", `.name // .login, "\(.id)+\(.login)@users.noreply.github.com"`,
+		}).Out(ctx)
+		if err != nil {
+			return fmt.Errorf("could not get the GitHub user: %w\n%s", err, out)
+		}
+		out, err = m.Stdout(ctx)
+		if err != nil {
+			return err
+		}
+		user := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+		if len(user) != 2 {
+			return fmt.Errorf("unexpected GitHub user: %q", out)
+		}
+		if name == "" {
+			name = user[0]
+		}
+		if email == "" {
+			email = user[1]
+		}
+	}
+
+	for _, config := range [][]string{{"user.name", name}, {"user.email", email}} {
+		out, err := m.WithGitExec(append([]string{"config", "--global"}, config...)).Out(ctx)
+		if err != nil {
+			return fmt.Errorf("could not configure git %s: %w\n%s", config[0], err, out)
+		}
+	}
+	m.gitUserConfigured = true
+	return nil
 }
@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestMatchBranch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		branch  string
+		want    bool
+	}{
+		{pattern: "main", branch: "main", want: true},
+		{pattern: "release/*", branch: "release/v1", want: true},
+		{pattern: "release/*", branch: "release/v1/fix", want: false},
+		{pattern: "release/**", branch: "release/v1/fix", want: true},
+		{pattern: "release/**", branch: "releases/v1", want: false},
+		{pattern: "**/fix", branch: "release/v1/fix", want: true},
+		{pattern: "**/fix", branch: "fix", want: true},
+		{pattern: "**/fix", branch: "prefix", want: false},
+		{pattern: "feat/**/wip-*", branch: "feat/a/b/wip-1", want: true},
+		{pattern: "feat/**/wip-*", branch: "feat/wip-1", want: true},
+		{pattern: "feat/**/wip-*", branch: "feat/a/wip-1/b", want: false},
+		{pattern: "**", branch: "any/branch", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.branch, func(t *testing.T) {
+			if got := matchBranch(tt.pattern, tt.branch); got != tt.want {
+				t.Errorf("matchBranch(%q, %q) = %v, want %v", tt.pattern, tt.branch, got, tt.want)
+			}
+		})
+	}
+}
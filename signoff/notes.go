@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Git notes ref used to record signoffs
+const notesRef = "refs/notes/signoff"
+
+// Attach a git note to the current commit recording who signed it off and when,
+// then push the notes to the origin remote.
+//
+// Notes are stored under refs/notes/signoff, use 'git log --notes=signoff' to display them.
+// If a note already exists on the commit, the new entry is appended to it.
+// Co-authors are recorded as 'Co-authored-by' trailers, with their GitHub name
+// and noreply email.
+func (m *Signoff) AttachNote(
+	ctx context.Context,
+	// Justification recorded with the note, typically the reason of a forced signoff
+	// +optional
+	reason string,
+	// GitHub logins of the co-authors
+	// +optional
+	coAuthors []string,
+) error {
+	sha, err := m.Sha(ctx)
+	if err != nil {
+		return err
+	}
+
+	user, err := m.WhoIs(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Get the existing notes first, the remote ref may not exist yet.
+	m.WithGitExec([]string{"fetch", "origin", "+" + notesRef + ":" + notesRef})
+
+	if err := m.configureGitUser(ctx); err != nil {
+		return err
+	}
+	note := fmt.Sprintf("%s signed off by %s on %s", m.CheckName, user, time.Now().UTC().Format(time.RFC3339))
+	if reason = strings.TrimSpace(reason); reason != "" {
+		note += "\nReason: " + reason
+	}
+	if len(coAuthors) > 0 {
+		note += "\n"
+		for _, login := range coAuthors {
+			coAuthor, err := m.coAuthor(ctx, login)
+			if err != nil {
+				return err
+			}
+			note += "\nCo-authored-by: " + coAuthor
+		}
+	}
+	out, err := m.WithGitExec([]string{
+		"notes", "--ref", notesRef,
+		"append", "-m", note, sha,
+	}).Out(ctx)
+	if err != nil {
+		return fmt.Errorf("could not add note to %s: %w\n%s", sha, err, out)
+	}
+
+	out, err = m.WithGitExec([]string{"push", "origin", notesRef}).Out(ctx)
+	if err != nil {
+		return fmt.Errorf("could not push notes: %w\n%s", err, out)
+	}
+
+	m.info("✓ Note attached to %s", sha)
+
+	return nil
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"dagger/signoff/internal/dagger"
+)
+
+// Branch protection, as returned by the GitHub API
+type branchProtection struct {
+	RequiredStatusChecks *struct {
+		Strict   bool     `json:"strict"`
+		Contexts []string `json:"contexts"`
+		Checks   []struct {
+			Context string `json:"context"`
+			// App required to set the check, any app if not set
+			AppID *int `json:"app_id"`
+		} `json:"checks"`
+	} `json:"required_status_checks"`
+	EnforceAdmins              *protectionSetting `json:"enforce_admins"`
+	RequiredPullRequestReviews *struct {
+		DismissStaleReviews          bool              `json:"dismiss_stale_reviews"`
+		RequireCodeOwnerReviews      bool              `json:"require_code_owner_reviews"`
+		RequiredApprovingReviewCount int               `json:"required_approving_review_count"`
+		RequireLastPushApproval      bool              `json:"require_last_push_approval"`
+		DismissalRestrictions        *protectionActors `json:"dismissal_restrictions"`
+		BypassPullRequestAllowances  *protectionActors `json:"bypass_pull_request_allowances"`
+	} `json:"required_pull_request_reviews"`
+	Restrictions                   *protectionActors  `json:"restrictions"`
+	RequiredLinearHistory          *protectionSetting `json:"required_linear_history"`
+	AllowForcePushes               *protectionSetting `json:"allow_force_pushes"`
+	AllowDeletions                 *protectionSetting `json:"allow_deletions"`
+	BlockCreations                 *protectionSetting `json:"block_creations"`
+	RequiredConversationResolution *protectionSetting `json:"required_conversation_resolution"`
+	LockBranch                     *protectionSetting `json:"lock_branch"`
+	AllowForkSyncing               *protectionSetting `json:"allow_fork_syncing"`
+}
+
+// Boolean setting of a branch protection
+type protectionSetting struct {
+	Enabled bool `json:"enabled"`
+}
+
+// enabled returns whether the setting is present and enabled.
+func (s *protectionSetting) enabled() bool {
+	return s != nil && s.Enabled
+}
+
+// User, team or app of a branch protection
+type protectionActor struct {
+	Login string `json:"login"`
+	Slug  string `json:"slug"`
+}
+
+// Users, teams and apps of a branch protection setting
+type protectionActors struct {
+	Users []protectionActor `json:"users"`
+	Teams []protectionActor `json:"teams"`
+	Apps  []protectionActor `json:"apps"`
+}
+
+// request returns the users, teams and apps as set in a request.
+func (a *protectionActors) request() map[string]any {
+	return map[string]any{
+		"users": logins(a.Users),
+		"teams": slugs(a.Teams),
+		"apps":  slugs(a.Apps),
+	}
+}
+
+// logins returns the logins of users.
+func logins(actors []protectionActor) []string {
+	l := []string{}
+	for _, a := range actors {
+		l = append(l, a.Login)
+	}
+	return l
+}
+
+// slugs returns the slugs of teams or apps.
+func slugs(actors []protectionActor) []string {
+	l := []string{}
+	for _, a := range actors {
+		l = append(l, a.Slug)
+	}
+	return l
+}
+
+// request returns the body of the request updating a branch protection to the same settings.
+func (p *branchProtection) request() map[string]any {
+	req := map[string]any{
+		"required_status_checks":           nil,
+		"enforce_admins":                   p.EnforceAdmins.enabled(),
+		"required_pull_request_reviews":    nil,
+		"restrictions":                     nil,
+		"required_linear_history":          p.RequiredLinearHistory.enabled(),
+		"allow_force_pushes":               p.AllowForcePushes.enabled(),
+		"allow_deletions":                  p.AllowDeletions.enabled(),
+		"block_creations":                  p.BlockCreations.enabled(),
+		"required_conversation_resolution": p.RequiredConversationResolution.enabled(),
+		"lock_branch":                      p.LockBranch.enabled(),
+		"allow_fork_syncing":               p.AllowForkSyncing.enabled(),
+	}
+	if c := p.RequiredStatusChecks; c != nil {
+		checks := map[string]any{"strict": c.Strict}
+		if len(c.Checks) > 0 {
+			// Checks replace the contexts, keeping the apps required to set them
+			var l []map[string]any
+			for _, check := range c.Checks {
+				item := map[string]any{"context": check.Context}
+				if check.AppID != nil {
+					item["app_id"] = *check.AppID
+				}
+				l = append(l, item)
+			}
+			checks["checks"] = l
+		} else {
+			checks["contexts"] = append([]string{}, c.Contexts...)
+		}
+		req["required_status_checks"] = checks
+	}
+	if r := p.RequiredPullRequestReviews; r != nil {
+		reviews := map[string]any{
+			"dismiss_stale_reviews":           r.DismissStaleReviews,
+			"require_code_owner_reviews":      r.RequireCodeOwnerReviews,
+			"required_approving_review_count": r.RequiredApprovingReviewCount,
+			"require_last_push_approval":      r.RequireLastPushApproval,
+		}
+		if d := r.DismissalRestrictions; d != nil {
+			reviews["dismissal_restrictions"] = d.request()
+		}
+		if b := r.BypassPullRequestAllowances; b != nil {
+			reviews["bypass_pull_request_allowances"] = b.request()
+		}
+		req["required_pull_request_reviews"] = reviews
+	}
+	if r := p.Restrictions; r != nil {
+		req["restrictions"] = r.request()
+	}
+	return req
+}
+
+// Verify the protection of the defined branch or of the default one matches the policy set by install.
+//
+// The returned error lists the differences with the policy: missing or extra
+// required checks, strict mode, admin enforcement, required reviews or push
+// restrictions. Run install to remediate them.
+func (m *Signoff) VerifyPolicy(
+	ctx context.Context,
+	// Branch to verify. If not set, the configured or default branch will be used
+	// +optional
+	branch string,
+) error {
+	branch, err := m.targetBranch(ctx, branch)
+	if err != nil {
+		return err
+	}
+
+	diff, err := m.policyDiff(ctx, branch)
+	if err != nil {
+		return err
+	}
+	if len(diff) > 0 {
+		return fmt.Errorf("branch %q protection differs from the signoff policy:\n%s", branch, strings.Join(diff, "\n"))
+	}
+
+	m.info("✓ GitHub %s branch protection matches the signoff policy", branch)
+
+	return nil
+}
+
+// Describe the drift of the protection of the defined branch or of the default one from the signoff policy, as markdown.
+//
+// The differences are listed in a diff code block, lines starting with '-' being
+// settings of the policy missing from the protection and lines starting with '+'
+// settings of the protection not part of the policy. The output can be rendered
+// with glow. It is 'no drift' when the protection matches the policy.
+func (m *Signoff) ProtectionDiff(
+	ctx context.Context,
+	// Branch to compare. If not set, the configured or default branch will be used
+	// +optional
+	branch string,
+) (string, error) {
+	branch, err := m.targetBranch(ctx, branch)
+	if err != nil {
+		return "", err
+	}
+
+	diff, err := m.policyDiff(ctx, branch)
+	if err != nil {
+		return "", err
+	}
+	if len(diff) == 0 {
+		return "no drift", nil
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Branch protection drift of `%s`\n\n", branch)
+	fmt.Fprintf(&b, "%d difference(s) with the signoff policy, run install to remediate them.\n\n", len(diff))
+	b.WriteString("```diff\n")
+	for _, d := range diff {
+		b.WriteString(d + "\n")
+	}
+	b.WriteString("```\n")
+	return b.String(), nil
+}
+
+// policyDiff returns the differences between the protection of the branch and the one set by Install.
+func (m *Signoff) policyDiff(ctx context.Context, branch string) ([]string, error) {
+	out, err := m.WithGhExec([]string{
+		"api",
+		fmt.Sprintf("/repos/:owner/:repo/branches/%s/protection", branch),
+	}).Out(ctx)
+	if err != nil {
+		if strings.Contains(out, "HTTP 404") {
+			return []string{"- branch is not protected"}, nil
+		}
+		return nil, fmt.Errorf("could not get branch protection for branch %q: %w\n%s", branch, err, out)
+	}
+	out, err = m.Stdout(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var protection branchProtection
+	if err := json.Unmarshal([]byte(out), &protection); err != nil {
+		return nil, fmt.Errorf("could not parse branch protection: %w", err)
+	}
+
+	var diff []string
+	if checks := protection.RequiredStatusChecks; checks == nil {
+		diff = append(diff, fmt.Sprintf("- missing required check %q", m.CheckName))
+	} else {
+		if !slices.Contains(checks.Contexts, m.CheckName) {
+			diff = append(diff, fmt.Sprintf("- missing required check %q", m.CheckName))
+		}
+		for _, c := range checks.Contexts {
+			if c != m.CheckName {
+				diff = append(diff, fmt.Sprintf("+ extra required check %q", c))
+			}
+		}
+		if checks.Strict {
+			diff = append(diff, "+ branches must be up to date before merging")
+		}
+	}
+	if protection.EnforceAdmins.enabled() {
+		diff = append(diff, "+ protection enforced for admins")
+	}
+	if protection.RequiredPullRequestReviews != nil {
+		diff = append(diff, "+ pull request reviews required")
+	}
+	if protection.Restrictions != nil {
+		diff = append(diff, "+ push restricted to some users, teams or apps")
+	}
+	return diff, nil
+}
+
+// Export the protection of the defined branch or of the default one as JSON.
+//
+// The snapshot can be restored later with restore-protection, to revert any
+// change made in the meantime, for instance by install. The snapshot of an
+// unprotected branch is an empty JSON object.
+func (m *Signoff) SnapshotProtection(
+	ctx context.Context,
+	// Branch to export the protection of. If not set, the configured or default branch will be used
+	// +optional
+	branch string,
+) (*dagger.File, error) {
+	branch, err := m.targetBranch(ctx, branch)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := m.WithGhExec([]string{
+		"api",
+		fmt.Sprintf("/repos/:owner/:repo/branches/%s/protection", branch),
+	}).Out(ctx)
+	if err != nil {
+		// Other not found errors, like an unknown branch, must not be saved as an unprotected branch
+		if !strings.Contains(out, "Branch not protected (HTTP 404)") {
+			return nil, fmt.Errorf("could not get branch protection for branch %q: %w\n%s", branch, err, out)
+		}
+		out = "{}"
+	} else if out, err = m.Stdout(ctx); err != nil {
+		return nil, err
+	}
+
+	return dag.Directory().WithNewFile("protection.json", out).File("protection.json"), nil
+}
+
+// Restore the protection of the defined branch or of the default one from a snapshot.
+//
+// All the settings of the snapshot are applied back. The snapshot of an
+// unprotected branch removes the protection.
+func (m *Signoff) RestoreProtection(
+	ctx context.Context,
+	// Branch to restore the protection of. If not set, the configured or default branch will be used
+	// +optional
+	branch string,
+	// Snapshot of the protection, exported by snapshot-protection
+	snapshot dagger.File,
+) error {
+	branch, err := m.targetBranch(ctx, branch)
+	if err != nil {
+		return err
+	}
+
+	c, err := snapshot.Contents(ctx)
+	if err != nil {
+		return fmt.Errorf("could not read snapshot: %w", err)
+	}
+	var protection branchProtection
+	if err := json.Unmarshal([]byte(c), &protection); err != nil {
+		return fmt.Errorf("could not parse snapshot: %w", err)
+	}
+
+	if strings.TrimSpace(c) == "{}" {
+		out, err := m.WithGhExec([]string{
+			"api",
+			fmt.Sprintf("/repos/:owner/:repo/branches/%s/protection", branch),
+			"--method", "DELETE",
+		}).Out(ctx)
+		if err != nil && !strings.Contains(out, "HTTP 404") {
+			return fmt.Errorf("could not remove branch protection for branch %q: %w\n%s", branch, err, out)
+		}
+		m.info("✓ GitHub %s branch protection removed", branch)
+		return nil
+	}
+
+	// The snapshot comes from the API, it can't fail to be marshaled back.
+	body, _ := json.Marshal(protection.request())
+	out, err := m.withGhExecStdin([]string{
+		"api",
+		fmt.Sprintf("/repos/:owner/:repo/branches/%s/protection", branch),
+		"--method", "PUT",
+		"-H", "Accept: application/vnd.github+json",
+		"-H", "X-GitHub-Api-Version: 2022-11-28",
+		"--input", "-",
+	}, string(body)).Out(ctx)
+	if err != nil {
+		return fmt.Errorf("could not restore branch protection for branch %q: %w\n%s", branch, err, out)
+	}
+
+	m.info("✓ GitHub %s branch protection restored", branch)
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestBranchProtectionRequest(t *testing.T) {
+	tests := []struct {
+		name     string
+		snapshot string
+		want     string
+	}{
+		{
+			name: "full protection",
+			snapshot: `{
+				"url": "https://api.github.com/repos/o/r/branches/main/protection",
+				"required_status_checks": {
+					"strict": true,
+					"contexts": ["signoff", "ci"],
+					"checks": [{"context": "signoff", "app_id": null}, {"context": "ci", "app_id": 15368}]
+				},
+				"enforce_admins": {"enabled": true},
+				"required_pull_request_reviews": {
+					"dismiss_stale_reviews": true,
+					"require_code_owner_reviews": true,
+					"required_approving_review_count": 2,
+					"require_last_push_approval": false,
+					"dismissal_restrictions": {
+						"users": [{"login": "alice"}],
+						"teams": [{"slug": "core"}],
+						"apps": [{"slug": "bot"}]
+					},
+					"bypass_pull_request_allowances": {
+						"users": [{"login": "bob"}],
+						"teams": [],
+						"apps": [{"slug": "release"}]
+					}
+				},
+				"restrictions": {"users": [], "teams": [{"slug": "core"}], "apps": []},
+				"required_linear_history": {"enabled": true},
+				"allow_force_pushes": {"enabled": false},
+				"allow_deletions": {"enabled": false},
+				"block_creations": {"enabled": false},
+				"required_conversation_resolution": {"enabled": true},
+				"lock_branch": {"enabled": false},
+				"allow_fork_syncing": {"enabled": false}
+			}`,
+			want: `{
+				"required_status_checks": {
+					"strict": true,
+					"checks": [{"context": "signoff"}, {"context": "ci", "app_id": 15368}]
+				},
+				"enforce_admins": true,
+				"required_pull_request_reviews": {
+					"dismiss_stale_reviews": true,
+					"require_code_owner_reviews": true,
+					"required_approving_review_count": 2,
+					"require_last_push_approval": false,
+					"dismissal_restrictions": {"users": ["alice"], "teams": ["core"], "apps": ["bot"]},
+					"bypass_pull_request_allowances": {"users": ["bob"], "teams": [], "apps": ["release"]}
+				},
+				"restrictions": {"users": [], "teams": ["core"], "apps": []},
+				"required_linear_history": true,
+				"allow_force_pushes": false,
+				"allow_deletions": false,
+				"block_creations": false,
+				"required_conversation_resolution": true,
+				"lock_branch": false,
+				"allow_fork_syncing": false
+			}`,
+		},
+		{
+			name:     "contexts only",
+			snapshot: `{"required_status_checks": {"strict": false, "contexts": ["signoff"]}, "enforce_admins": {"enabled": false}}`,
+			want: `{
+				"required_status_checks": {"strict": false, "contexts": ["signoff"]},
+				"enforce_admins": false,
+				"required_pull_request_reviews": null,
+				"restrictions": null,
+				"required_linear_history": false,
+				"allow_force_pushes": false,
+				"allow_deletions": false,
+				"block_creations": false,
+				"required_conversation_resolution": false,
+				"lock_branch": false,
+				"allow_fork_syncing": false
+			}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var protection branchProtection
+			if err := json.Unmarshal([]byte(tt.snapshot), &protection); err != nil {
+				t.Fatal(err)
+			}
+			got, err := json.Marshal(protection.request())
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Compare the normalized JSON, the keys being sorted
+			var want any
+			if err := json.Unmarshal([]byte(tt.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			wantJSON, _ := json.Marshal(want)
+			if string(got) != string(wantJSON) {
+				t.Errorf("request() = %s, want %s", got, wantJSON)
+			}
+		})
+	}
+}
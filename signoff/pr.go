@@ -0,0 +1,537 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Interval between two polls of the GitHub API
+const pollInterval = 15 * time.Second
+
+// Mergeability of a pull request
+type Mergeability struct {
+	// Whether the pull request can be merged
+	Mergeable bool
+	// Human-readable reason explaining the mergeability
+	Reason string
+}
+
+// Check if the pull request of the current branch can be merged according to
+// the branch protection.
+//
+// When blocked, the pending and failing checks of its head commit are listed.
+func (m *Signoff) IsMergeable(ctx context.Context) (*Mergeability, error) {
+	out, err := m.WithGhExec([]string{
+		"pr", "view",
+		"--json", "mergeable,mergeStateStatus,headRefOid",
+	}).Out(ctx)
+	if err != nil {
+		if strings.Contains(out, "no pull requests found") {
+			return nil, fmt.Errorf("no pull request found for the current branch")
+		}
+		return nil, fmt.Errorf("could not get the pull request of the current branch: %w\n%s", err, out)
+	}
+	out, err = m.Stdout(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var pr struct {
+		Mergeable        string `json:"mergeable"`
+		MergeStateStatus string `json:"mergeStateStatus"`
+		HeadRefOid       string `json:"headRefOid"`
+	}
+	if err := json.Unmarshal([]byte(out), &pr); err != nil {
+		return nil, fmt.Errorf("could not parse pull request: %w", err)
+	}
+
+	if pr.Mergeable == "CONFLICTING" {
+		return &Mergeability{Reason: "conflicting: the branch has conflicts with the base branch"}, nil
+	}
+
+	switch pr.MergeStateStatus {
+	case "CLEAN":
+		return &Mergeability{Mergeable: true, Reason: "clean: all requirements are satisfied"}, nil
+	case "HAS_HOOKS":
+		return &Mergeability{Mergeable: true, Reason: "has hooks: mergeable with passing commit status and pre-receive hooks"}, nil
+	case "UNSTABLE":
+		return &Mergeability{Mergeable: true, Reason: "unstable: mergeable but some non required checks are failing"}, nil
+	case "BEHIND":
+		return &Mergeability{Reason: "behind: the branch is not up to date with the base branch"}, nil
+	case "DIRTY":
+		return &Mergeability{Reason: "dirty: the merge commit cannot be cleanly created"}, nil
+	case "DRAFT":
+		return &Mergeability{Reason: "draft: the pull request is a draft"}, nil
+	case "BLOCKED":
+		state, err := m.commitState(ctx, pr.HeadRefOid)
+		if err != nil {
+			return nil, err
+		}
+		var reasons []string
+		for _, c := range state.Contexts {
+			switch c.State {
+			case "PENDING", "EXPECTED":
+				reasons = append(reasons, c.Name+" check pending")
+			case "FAILURE", "ERROR", "TIMED_OUT", "CANCELLED", "STARTUP_FAILURE":
+				reasons = append(reasons, c.Name+" check failing")
+			}
+		}
+		if len(reasons) == 0 {
+			return &Mergeability{Reason: "blocked: required reviews or checks are missing"}, nil
+		}
+		return &Mergeability{Reason: "blocked: " + strings.Join(reasons, ", ")}, nil
+	default:
+		return &Mergeability{Reason: "unknown: GitHub has not computed the mergeability yet, try again later"}, nil
+	}
+}
+
+// Approve the pull request of the current branch with a review.
+func (m *Signoff) Approve(
+	ctx context.Context,
+	// Body of the review
+	// +optional
+	body string,
+) error {
+	args := []string{"pr", "review", "--approve"}
+	if body != "" {
+		args = append(args, "--body", body)
+	}
+	out, err := m.WithGhExec(args).Out(ctx)
+	if err != nil {
+		if strings.Contains(strings.ToLower(out), "can not approve your own pull request") {
+			return fmt.Errorf("GitHub does not allow the author of a pull request to approve it, ask another maintainer to approve it or use Create to sign off with a status")
+		}
+		return fmt.Errorf("could not approve the pull request: %w\n%s", err, out)
+	}
+
+	m.info("✓ Pull request approved")
+
+	return nil
+}
+
+// Sign off the current commit and enable auto-merge on the pull request of the current branch.
+//
+// GitHub then merges the pull request once all its requirements are satisfied,
+// instead of merging it immediately. Auto-merge must be allowed in the settings
+// of the repository.
+func (m *Signoff) SignoffAndAutoMerge(
+	ctx context.Context,
+	// Merge method, 'merge', 'squash' or 'rebase'
+	// +optional
+	// +default="merge"
+	method string,
+) error {
+	switch method {
+	case "merge", "squash", "rebase":
+	default:
+		return fmt.Errorf("unknown merge method %q, must be 'merge', 'squash' or 'rebase'", method)
+	}
+
+	out, err := m.Create(ctx, false, "", false, nil, false)
+	if err != nil {
+		return err
+	}
+	m.info("%s", out)
+
+	out, err = m.WithGhExec([]string{"pr", "merge", "--auto", "--" + method}).Out(ctx)
+	if err != nil {
+		if strings.Contains(strings.ToLower(out), "auto merge is not allowed") {
+			return fmt.Errorf("auto-merge is disabled on the repository, enable 'Allow auto-merge' in its settings: %w", err)
+		}
+		return fmt.Errorf("could not enable auto-merge: %w\n%s", err, out)
+	}
+
+	m.info("✓ Auto-merge enabled, the pull request will be merged once its requirements are satisfied")
+
+	return nil
+}
+
+// Get the list of files changed between the base and the current commit.
+func (m *Signoff) ChangedFiles(
+	ctx context.Context,
+	// Base revision to compare to, default to the default branch of the origin remote
+	// +optional
+	base string,
+) ([]string, error) {
+	if base == "" {
+		defaultBranch, err := m.DefaultBranch(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("could not get the default branch: %w", err)
+		}
+		base = "origin/" + defaultBranch
+	}
+
+	out, err := m.WithGitExec([]string{"diff", "--name-only", base + "...HEAD"}).Out(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not list changed files since %s: %w\n%s", base, err, out)
+	}
+	out, err = m.Stdout(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{}
+	for _, file := range strings.Split(out, "\n") {
+		if file = strings.TrimSpace(file); file != "" {
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+// Sign off the current commit if it changes files matching the paths, mark it as
+// not requiring a signoff otherwise.
+//
+// The changed files are the ones since the default branch. The paths are globs,
+// as supported by path.Match, a directory matching all the files it contains.
+// When no changed file matches, the status is posted without running the clean
+// checks, as there is nothing to review.
+func (m *Signoff) CreateIfPathsChanged(
+	ctx context.Context,
+	// Globs of the paths requiring a signoff, e.g. 'services/api' or 'docs/*.md'
+	paths []string,
+) error {
+	for _, p := range paths {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("invalid path %q: %w", p, err)
+		}
+	}
+
+	files, err := m.ChangedFiles(ctx, "")
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if !matchesPath(paths, file) {
+			continue
+		}
+		m.info("Signoff required, %s changed", file)
+		out, err := m.Create(ctx, false, "", false, nil, false)
+		if err != nil {
+			return err
+		}
+		m.info("%s", out)
+		return nil
+	}
+
+	if err := m.checkScopes(ctx, scopeStatus); err != nil {
+		return err
+	}
+	sha, err := m.Sha(ctx)
+	if err != nil {
+		return err
+	}
+	if err := m.createStatus(ctx, sha, "no signoff required, no matching path changed"); err != nil {
+		return err
+	}
+
+	m.info("✓ No signoff required on %s, none of the %d changed files match", sha, len(files))
+
+	return nil
+}
+
+// matchesPath returns whether the file, or one of its parent directories, matches one of the globs.
+// The globs have already been validated.
+func matchesPath(globs []string, file string) bool {
+	for p := file; p != "." && p != "/"; p = path.Dir(p) {
+		for _, glob := range globs {
+			if ok, _ := path.Match(glob, p); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Sign off all the commits of a pull request, using the GitHub API only.
+//
+// This does not require the pull request branch to be checked out locally.
+// Every commit is processed even if some of them fail, the failures being
+// reported in the returned error.
+func (m *Signoff) CreatePRStatuses(
+	ctx context.Context,
+	// Number of the pull request
+	pr int,
+) error {
+	user, err := m.WhoIs(ctx)
+	if err != nil {
+		return err
+	}
+
+	out, err := m.WithGhExec([]string{
+		"api", "--paginate",
+		fmt.Sprintf("repos/:owner/:repo/pulls/%d/commits", pr),
+		"--jq", ".[].sha",
+	}).Out(ctx)
+	if err != nil {
+		return fmt.Errorf("could not list commits of pull request #%d: %w\n%s", pr, err, out)
+	}
+	out, err = m.Stdout(ctx)
+	if err != nil {
+		return err
+	}
+
+	var failed []string
+	for _, sha := range strings.Fields(out) {
+		if err := m.createStatus(ctx, sha, user+" signed off"); err != nil {
+			m.info("✗ Could not sign off %s: %v", sha, err)
+			failed = append(failed, sha)
+			continue
+		}
+		m.info("✓ Signed off on %s", sha)
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("could not sign off %d commits of pull request #%d: %s", len(failed), pr, strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// Wait until the reviewer approves the pull request of the current branch.
+//
+// Reviews are polled until the reviewer submits an approving review or the timeout elapses.
+// On timeout, the returned error contains the last review state of the reviewer,
+// ignoring the comment-only reviews which don't change it.
+func (m *Signoff) WaitForApproval(
+	ctx context.Context,
+	// GitHub login of the reviewer
+	reviewer string,
+	// Maximum time to wait, in seconds
+	// +optional
+	// +default=600
+	timeout int,
+) error {
+	deadline := time.Now().Add(time.Duration(timeout) * time.Second)
+	state := "none"
+	for {
+		out, err := m.WithGhExec([]string{
+			"pr", "view",
+			"--json", "reviews",
+			// Comments don't change the effective state of the review, and pending reviews are not submitted
+			"--jq", fmt.Sprintf("[.reviews[] | select(.author.login == %q and .state != \"COMMENTED\" and .state != \"PENDING\")] | last | .state // \"\"", reviewer),
+		}).Out(ctx)
+		if err != nil {
+			return fmt.Errorf("could not get the pull request reviews: %w\n%s", err, out)
+		}
+		out, err = m.Stdout(ctx)
+		if err != nil {
+			return err
+		}
+		if s := strings.TrimSpace(out); s != "" {
+			state = s
+		}
+		if state == "APPROVED" {
+			m.info("✓ Pull request approved by %s", reviewer)
+			return nil
+		}
+
+		if time.Now().Add(pollInterval).After(deadline) {
+			return fmt.Errorf("timeout waiting for %s approval, last review state: %s", reviewer, strings.ToLower(state))
+		}
+		m.debug("waiting for %s approval, last review state: %s", reviewer, strings.ToLower(state))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// Get the SHA of the commit created by merging a pull request.
+//
+// This is the merge, squash or rebase commit, to tag or log the exact merged commit.
+// The result is empty if the pull request is not merged yet, for instance when
+// it is waiting in a merge queue.
+func (m *Signoff) MergeCommit(
+	ctx context.Context,
+	// Number of the pull request, default to the one of the current branch
+	// +optional
+	pr int,
+) (string, error) {
+	args := []string{"pr", "view"}
+	if pr > 0 {
+		args = append(args, strconv.Itoa(pr))
+	}
+	out, err := m.WithGhExec(append(args, "--json", "state,mergeCommit")).Out(ctx)
+	if err != nil {
+		return "", fmt.Errorf("could not get the pull request: %w\n%s", err, out)
+	}
+	out, err = m.Stdout(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var resp struct {
+		State       string `json:"state"`
+		MergeCommit *struct {
+			Oid string `json:"oid"`
+		} `json:"mergeCommit"`
+	}
+	if err := json.Unmarshal([]byte(out), &resp); err != nil {
+		return "", fmt.Errorf("could not parse pull request: %w", err)
+	}
+	if resp.State != "MERGED" || resp.MergeCommit == nil {
+		m.info("Pull request is %s, not merged yet", strings.ToLower(resp.State))
+		return "", nil
+	}
+	return resp.MergeCommit.Oid, nil
+}
+
+// List the open pull requests whose head commit is not signed off yet.
+//
+// The pull requests are listed in a table with their number, title, author and url.
+func (m *Signoff) PendingSignoffs(ctx context.Context) (string, error) {
+	// gh paginates the API calls up to the limit
+	out, err := m.WithGhExec([]string{
+		"pr", "list",
+		"--state", "open",
+		"--limit", "1000",
+		"--json", "number,title,author,url,headRefOid",
+	}).Out(ctx)
+	if err != nil {
+		return "", fmt.Errorf("could not list the open pull requests: %w\n%s", err, out)
+	}
+	out, err = m.Stdout(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var prs []struct {
+		Number int    `json:"number"`
+		Title  string `json:"title"`
+		URL    string `json:"url"`
+		Head   string `json:"headRefOid"`
+		Author struct {
+			Login string `json:"login"`
+		} `json:"author"`
+	}
+	if err := json.Unmarshal([]byte(out), &prs); err != nil {
+		return "", fmt.Errorf("could not parse pull requests: %w", err)
+	}
+
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PR\tTITLE\tAUTHOR\tURL")
+	pending := 0
+	for _, pr := range prs {
+		status, err := m.Status(ctx, pr.Head)
+		if err != nil {
+			return "", err
+		}
+		if status != "success" {
+			fmt.Fprintf(w, "#%d\t%s\t%s\t%s\n", pr.Number, pr.Title, pr.Author.Login, pr.URL)
+			pending++
+		}
+	}
+	if pending == 0 {
+		return "No open pull request waiting for " + m.CheckName, nil
+	}
+	w.Flush()
+	return strings.TrimRight(b.String(), "\n"), nil
+}
+
+// Marker identifying the preflight report comment, to update it in place
+const reportMarker = "<!-- signoff-preflight-report -->"
+
+// Run the preflight gates and post the report as a comment on the pull request of the current branch.
+//
+// The comment is updated on the next runs instead of adding a new one.
+// Nothing is posted if the current branch has no pull request.
+func (m *Signoff) ReportToPR(ctx context.Context) error {
+	out, err := m.WithGhExec([]string{"pr", "view", "--json", "number", "--jq", ".number"}).Out(ctx)
+	if err != nil {
+		// gh fails with 'no pull requests found for branch "..."'
+		if strings.Contains(out, "no pull requests found") {
+			m.info("No pull request found for the current branch, skipping the report")
+			return nil
+		}
+		return fmt.Errorf("could not get the pull request of the current branch: %w\n%s", err, out)
+	}
+	out, err = m.Stdout(ctx)
+	if err != nil {
+		return err
+	}
+	pr := strings.TrimSpace(out)
+
+	report, err := m.Preflight(ctx)
+	if err != nil {
+		return err
+	}
+	sha, err := m.Sha(ctx)
+	if err != nil {
+		return err
+	}
+	body := fmt.Sprintf("%s\n### %s preflight report\n\nCommit %s\n\n%s", reportMarker, m.CheckName, sha, report.markdown())
+
+	out, err = m.WithGhExec([]string{
+		"api", "--paginate",
+		"repos/:owner/:repo/issues/" + pr + "/comments",
+		"--jq", fmt.Sprintf(".[] | select(.body | startswith(%q)) | .id", reportMarker),
+	}).Out(ctx)
+	if err != nil {
+		return fmt.Errorf("could not list the comments of pull request #%s: %w\n%s", pr, err, out)
+	}
+	out, err = m.Stdout(ctx)
+	if err != nil {
+		return err
+	}
+
+	args := []string{"api", "--method", "POST", "repos/:owner/:repo/issues/" + pr + "/comments"}
+	if ids := strings.Fields(out); len(ids) > 0 {
+		args = []string{"api", "--method", "PATCH", "repos/:owner/:repo/issues/comments/" + ids[0]}
+	}
+	out, err = m.WithGhExec(append(args, "-f", "body="+body)).Out(ctx)
+	if err != nil {
+		return fmt.Errorf("could not comment pull request #%s: %w\n%s", pr, err, out)
+	}
+
+	m.info("✓ Preflight report posted on pull request #%s", pr)
+
+	return nil
+}
+
+// Directories where GitHub looks for pull request templates, by order of precedence
+var prTemplateDirs = []string{".github/", "", "docs/"}
+
+// prTemplate returns the contents of the pull request template of the sources,
+// empty if there is none. The name selects a template of a 'PULL_REQUEST_TEMPLATE'
+// directory, the single 'pull_request_template.md' file being used otherwise.
+// Names are case-insensitive, as for GitHub.
+func (m *Signoff) prTemplate(ctx context.Context, name string) (string, error) {
+	for _, dir := range prTemplateDirs {
+		pattern := dir + "*.md"
+		if name != "" {
+			pattern = dir + "*/*.md"
+		}
+		files, err := m.Sources.Glob(ctx, pattern)
+		if err != nil {
+			return "", fmt.Errorf("could not list pull request templates: %w", err)
+		}
+		for _, file := range files {
+			base := path.Base(file)
+			if name == "" && !strings.EqualFold(base, "pull_request_template.md") {
+				continue
+			}
+			if name != "" && (!strings.EqualFold(path.Base(path.Dir(file)), "pull_request_template") ||
+				!(strings.EqualFold(base, name) || strings.EqualFold(base, name+".md"))) {
+				continue
+			}
+			content, err := m.Sources.File(file).Contents(ctx)
+			if err != nil {
+				return "", fmt.Errorf("could not read %s: %w", file, err)
+			}
+			return content, nil
+		}
+	}
+	if name != "" {
+		return "", fmt.Errorf("pull request template %q not found", name)
+	}
+	return "", nil
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+const (
+	// Scope required to post commit statuses
+	scopeStatus = "repo:status"
+	// Scope required to administrate the branch protection
+	scopeRepo = "repo"
+	// Scope required to create deployments and deployment statuses
+	scopeDeployment = "repo_deployment"
+	// Scope granting the access to the public repositories only
+	scopePublicRepo = "public_repo"
+)
+
+// Check the token has the scopes required by all the operations.
+//
+// Posting statuses requires the 'repo:status' scope, installing or uninstalling
+// the branch protection requires the 'repo' scope, which also grants the
+// 'repo_deployment' scope used by create-deployment.
+// On public repositories, the 'public_repo' scope is enough.
+//
+// Fine-grained tokens have permissions instead of scopes, respectively 'Commit
+// statuses', 'Administration' and 'Deployments'. They are verified by probing
+// the API with invalid writes, rejected without modifying anything.
+func (m *Signoff) CheckScopes(ctx context.Context) error {
+	return m.checkScopes(ctx, scopeStatus, scopeRepo)
+}
+
+// Permissions of fine-grained tokens corresponding to the scopes
+var scopePermissions = map[string]string{
+	scopeStatus:     "Commit statuses: write",
+	scopeRepo:       "Administration: read and write",
+	scopeDeployment: "Deployments: write",
+}
+
+// checkScopes verifies the token has the required scopes on the repository of the sources
+// and its current commit.
+func (m *Signoff) checkScopes(ctx context.Context, required ...string) error {
+	return m.checkScopesOn(ctx, ":owner/:repo", "", required...)
+}
+
+// checkScopesOn verifies the token has the required scopes, based on the X-OAuth-Scopes header.
+// Tokens without such header (fine-grained tokens, GitHub App tokens) have their
+// permissions probed instead, on the repository and the commit, the current one
+// if empty, the operations apply to.
+func (m *Signoff) checkScopesOn(ctx context.Context, repo, sha string, required ...string) error {
+	out, err := m.WithGhExec([]string{"api", "--include", "user"}).Out(ctx)
+	if err != nil {
+		return fmt.Errorf("could not get the token scopes: %w\n%s", err, out)
+	}
+
+	scopes, ok := oauthScopes(out)
+	if !ok {
+		m.debug("no X-OAuth-Scopes header, probing the token permissions")
+		return m.checkPermissions(ctx, repo, sha, required...)
+	}
+
+	var missing []string
+	for _, scope := range required {
+		if !hasScope(scopes, scope) {
+			missing = append(missing, scope)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("the GitHub token is missing the %s scope(s), add them to the token at https://github.com/settings/tokens", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// checkPermissions verifies the token has the permissions corresponding to the required scopes
+// on the repository, probing the API with requests failing on purpose once the permission is checked.
+func (m *Signoff) checkPermissions(ctx context.Context, repo, sha string, required ...string) error {
+	var missing []string
+	for _, scope := range required {
+		granted, err := m.probePermission(ctx, repo, sha, scope)
+		if err != nil {
+			return err
+		}
+		if !granted {
+			missing = append(missing, "'"+scopePermissions[scope]+"'")
+		}
+	}
+	if len(missing) > 0 {
+		target := "the repository"
+		if repo != ":owner/:repo" {
+			target = repo
+		}
+		return fmt.Errorf("the GitHub token is missing the %s permission(s) on %s, grant them to the token at https://github.com/settings/personal-access-tokens", strings.Join(missing, ", "), target)
+	}
+	return nil
+}
+
+// probePermission returns whether the token has the permission corresponding to the scope
+// on the repository. The probes don't modify anything: the writes are invalid and
+// rejected once the permission is checked. The status probe targets the commit, the
+// current one if empty, and the administration probe the protection of the default branch.
+func (m *Signoff) probePermission(ctx context.Context, repo, sha, scope string) (bool, error) {
+	var args []string
+	switch scope {
+	case scopeStatus:
+		if sha == "" {
+			var err error
+			if sha, err = m.Sha(ctx); err != nil {
+				return false, err
+			}
+		}
+		args = []string{"api", "--method", "POST", "repos/" + repo + "/statuses/" + sha, "-f", "state=probe"}
+	case scopeRepo:
+		branch, err := m.repoDefaultBranch(ctx, repo)
+		if err != nil {
+			return false, err
+		}
+		// The required settings are missing
+		args = []string{"api", "--method", "PUT", "repos/" + repo + "/branches/" + branch + "/protection", "-f", "enforce_admins=probe"}
+	case scopeDeployment:
+		args = []string{"api", "--method", "POST", "repos/" + repo + "/deployments", "-f", "ref="}
+	default:
+		return false, fmt.Errorf("unknown scope %q", scope)
+	}
+
+	out, err := m.WithGhExec(args).Out(ctx)
+	if err == nil {
+		return true, nil
+	}
+	// Without the permission the request is forbidden, or the repository is not
+	// found when the token has no access to it. Any other error, like a validation
+	// failure, comes after the permission check.
+	if strings.Contains(out, "(HTTP 403)") || strings.Contains(out, "Not Found (HTTP 404)") {
+		m.debug("%s permission probe failed: %s", scope, strings.TrimSpace(out))
+		return false, nil
+	}
+	return true, nil
+}
+
+// repoDefaultBranch returns the default branch of the repository, the one of the
+// origin remote for the repository of the sources.
+func (m *Signoff) repoDefaultBranch(ctx context.Context, repo string) (string, error) {
+	if repo == ":owner/:repo" {
+		return m.DefaultBranch(ctx)
+	}
+	out, err := m.WithGhExec([]string{"api", "repos/" + repo, "--jq", ".default_branch"}).Out(ctx)
+	if err != nil {
+		return "", fmt.Errorf("could not get the default branch of %s: %w\n%s", repo, err, out)
+	}
+	out, err = m.Stdout(ctx)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// oauthScopes parses the X-OAuth-Scopes header of an HTTP response.
+func oauthScopes(response string) ([]string, bool) {
+	for _, line := range strings.Split(response, "\n") {
+		name, value, found := strings.Cut(line, ":")
+		if !found || !strings.EqualFold(strings.TrimSpace(name), "X-OAuth-Scopes") {
+			continue
+		}
+		var scopes []string
+		for _, scope := range strings.Split(value, ",") {
+			if scope = strings.TrimSpace(scope); scope != "" {
+				scopes = append(scopes, scope)
+			}
+		}
+		return scopes, true
+	}
+	return nil, false
+}
+
+// hasScope checks if the scope is granted, directly or through its parent scope.
+// The 'public_repo' scope grants all the scopes on public repositories, it is
+// accepted as GitHub rejects the requests on private ones anyway.
+func hasScope(scopes []string, scope string) bool {
+	parent, _, _ := strings.Cut(scope, ":")
+	if scope == scopeDeployment {
+		parent = scopeRepo
+	}
+	for _, s := range scopes {
+		if s == scope || s == parent || s == scopePublicRepo {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestOAuthScopes(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     []string
+		found    bool
+	}{
+		{
+			name:     "scopes",
+			response: "HTTP/2.0 200 OK\nX-Oauth-Scopes: repo, read:org\nX-Accepted-Oauth-Scopes: \n\n{}",
+			want:     []string{"repo", "read:org"},
+			found:    true,
+		},
+		{
+			name:     "no scope",
+			response: "HTTP/2.0 200 OK\nX-OAuth-Scopes: \n\n{}",
+			found:    true,
+		},
+		{
+			name:     "fine-grained token",
+			response: "HTTP/2.0 200 OK\nContent-Type: application/json\n\n{}",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := oauthScopes(tt.response)
+			if !slices.Equal(got, tt.want) || found != tt.found {
+				t.Errorf("oauthScopes() = %q, %v, want %q, %v", got, found, tt.want, tt.found)
+			}
+		})
+	}
+}
+
+func TestHasScope(t *testing.T) {
+	tests := []struct {
+		scopes []string
+		scope  string
+		want   bool
+	}{
+		{scopes: []string{"repo:status"}, scope: scopeStatus, want: true},
+		{scopes: []string{"repo"}, scope: scopeStatus, want: true},
+		{scopes: []string{"repo"}, scope: scopeRepo, want: true},
+		{scopes: []string{"repo"}, scope: scopeDeployment, want: true},
+		{scopes: []string{"repo_deployment"}, scope: scopeDeployment, want: true},
+		{scopes: []string{"public_repo"}, scope: scopeStatus, want: true},
+		{scopes: []string{"public_repo"}, scope: scopeRepo, want: true},
+		{scopes: []string{"public_repo"}, scope: scopeDeployment, want: true},
+		{scopes: []string{"repo:status"}, scope: scopeRepo, want: false},
+		{scopes: []string{"repo_deployment", "read:org"}, scope: scopeStatus, want: false},
+		{scopes: nil, scope: scopeStatus, want: false},
+	}
+	for _, tt := range tests {
+		if got := hasScope(tt.scopes, tt.scope); got != tt.want {
+			t.Errorf("hasScope(%q, %q) = %v, want %v", tt.scopes, tt.scope, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Timeout of the webhook notification
+const webhookTimeout = 10 * time.Second
+
+// Payload posted to the webhook after a successful signoff.
+//
+// The format is stable: fields may be added but are never renamed or removed.
+// 'text' and 'content' hold the same summary, so the payload can be posted as
+// is to Slack and Discord incoming webhooks.
+//
+//	{
+//	  "sha": "0123456789abcdef0123456789abcdef01234567",
+//	  "user": "octocat",
+//	  "branch": "main",
+//	  "check": "signoff",
+//	  "text": "octocat signed off main (0123456) with signoff",
+//	  "content": "octocat signed off main (0123456) with signoff"
+//	}
+type webhookPayload struct {
+	SHA     string `json:"sha"`
+	User    string `json:"user"`
+	Branch  string `json:"branch"`
+	Check   string `json:"check"`
+	Text    string `json:"text"`
+	Content string `json:"content"`
+}
+
+// notify posts the signoff to the webhook, if any.
+// Failures are only reported, the signoff being already done.
+func (m *Signoff) notify(ctx context.Context, sha, user string) {
+	if m.WebhookURL == nil {
+		return
+	}
+	if err := m.postWebhook(ctx, sha, user); err != nil {
+		m.warn("⚠ Could not notify the webhook: %v", err)
+		return
+	}
+	m.debug("webhook notified")
+}
+
+// postWebhook posts the payload of the signoff to the webhook.
+func (m *Signoff) postWebhook(ctx context.Context, sha, user string) error {
+	url, err := m.WebhookURL.Plaintext(ctx)
+	if err != nil {
+		return err
+	}
+	branch, err := m.CurrentBranch(ctx)
+	if err != nil {
+		return err
+	}
+
+	text := fmt.Sprintf("%s signed off %s (%.7s) with %s", user, branch, sha, m.CheckName)
+	body, err := json.Marshal(webhookPayload{
+		SHA:     sha,
+		User:    user,
+		Branch:  branch,
+		Check:   m.CheckName,
+		Text:    text,
+		Content: text,
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		// The url is not part of the error as it usually contains a token
+		return fmt.Errorf("invalid webhook url")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("could not post to the webhook")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return nil
+}